@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Achievement is unlocked once its conditions hold for long enough, such as
+//
+//	"when": "0x3F0 >= 10 and balls > 0 for 60 frames"
+type Achievement struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	When        string `json:"when"`
+
+	conditions []*Condition
+	frames     int // frames the conditions must hold in a row
+}
+
+func (ach *Achievement) compile(a *Annotation) error {
+	when := strings.Fields(ach.When)
+	ach.frames = 1
+	if n := len(when); n >= 3 && when[n-3] == "for" && (when[n-1] == "frames" || when[n-1] == "frame") {
+		frames, err := strconv.Atoi(when[n-2])
+		if err != nil || frames < 1 {
+			return fmt.Errorf("invalid number of frames %q", when[n-2])
+		}
+		ach.frames = frames
+		when = when[:n-3]
+	}
+	for _, s := range strings.Split(strings.Join(when, " "), " and ") {
+		c, err := a.ParseCondition(s)
+		if err != nil {
+			return err
+		}
+		ach.conditions = append(ach.conditions, c)
+	}
+	return nil
+}
+
+func (ach *Achievement) holds(e *Emulator) bool {
+	for _, c := range ach.conditions {
+		if !c.Eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Achievements evaluates the achievements of a game every frame
+type Achievements struct {
+	// OnUnlock, when set, is called when an achievement is unlocked
+	OnUnlock func(ach *Achievement)
+
+	rom      string // SHA-1 of the ROM
+	list     []*Achievement
+	held     []int // frames each achievement's conditions have held in a row
+	progress *Progress
+}
+
+// NewAchievements tracks the achievements of an annotated game, recording
+// the unlocks in progress
+func NewAchievements(a *Annotation, progress *Progress) *Achievements {
+	return &Achievements{
+		rom:      a.SHA1,
+		list:     a.Achievements,
+		held:     make([]int, len(a.Achievements)),
+		progress: progress,
+	}
+}
+
+// Update evaluates the achievements still locked after a frame
+func (t *Achievements) Update(e *Emulator) error {
+	for i, ach := range t.list {
+		if t.progress.Unlocked(t.rom, ach.Title) {
+			continue
+		}
+		if !ach.holds(e) {
+			t.held[i] = 0
+			continue
+		}
+		if t.held[i]++; t.held[i] < ach.frames {
+			continue
+		}
+		if err := t.progress.Unlock(t.rom, ach.Title); err != nil {
+			return err
+		}
+		if t.OnUnlock != nil {
+			t.OnUnlock(ach)
+		}
+	}
+	return nil
+}
+
+// Progress records the unlocked achievements by ROM, in a JSON file
+type Progress struct {
+	path     string
+	unlocked map[string]map[string]time.Time
+}
+
+// configPath returns the path of a file of the emulator in the user configuration directory
+func configPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chip8", name), nil
+}
+
+// DefaultProgressPath returns the progress file in the user configuration directory
+func DefaultProgressPath() (string, error) {
+	return configPath("achievements.json")
+}
+
+// LoadProgress reads a progress file, empty when it does not exist yet.
+// An empty path keeps the progress in memory only.
+func LoadProgress(path string) (*Progress, error) {
+	p := &Progress{path: path, unlocked: map[string]map[string]time.Time{}}
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &p.unlocked); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Unlocked reports whether an achievement of a ROM was unlocked
+func (p *Progress) Unlocked(rom, title string) bool {
+	_, ok := p.unlocked[rom][title]
+	return ok
+}
+
+// Unlock records an achievement as unlocked now and saves the progress
+func (p *Progress) Unlock(rom, title string) error {
+	if p.unlocked[rom] == nil {
+		p.unlocked[rom] = map[string]time.Time{}
+	}
+	p.unlocked[rom][title] = time.Now()
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.unlocked, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0644)
+}
+
+// trackAchievements unlocks the achievements of a ROM with a built-in
+// annotation at the end of every frame, showing a toast for each
+func trackAchievements(e *Emulator, rom []byte, progressPath string) error {
+	a := BuiltinAnnotations().Find(rom)
+	if a == nil || len(a.Achievements) == 0 {
+		return nil
+	}
+	if progressPath == "" {
+		var err error
+		if progressPath, err = DefaultProgressPath(); err != nil {
+			return err
+		}
+	}
+	progress, err := LoadProgress(progressPath)
+	if err != nil {
+		return err
+	}
+	achievements := NewAchievements(a, progress)
+	achievements.OnUnlock = func(ach *Achievement) {
+		log.Printf("achievement unlocked: %s\n", ach.Title)
+		e.Toast("Achievement: " + ach.Title)
+	}
+	e.OnFrame = func() {
+		if err := achievements.Update(e); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
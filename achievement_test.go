@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAchievements_Frames(t *testing.T) {
+	a, err := ParseAnnotation([]byte(`{
+		"title": "TEST",
+		"sha1": "0000",
+		"values": {"lives": {"location": "VE", "type": "byte"}},
+		"achievements": [
+			{"title": "Rich", "when": "0x3F0 >= 10 for 3 frames"},
+			{"title": "Rich and alive", "when": "0x3F0 >= 10 and lives > 0"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "chip8", "achievements.json")
+	progress, err := LoadProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unlocked []string
+	achievements := NewAchievements(a, progress)
+	achievements.OnUnlock = func(ach *Achievement) {
+		unlocked = append(unlocked, ach.Title)
+	}
+
+	emu := NewEmulator(NewFonts())
+	for _, value := range []uint8{10, 12, 0, 10, 11, 12, 13} {
+		emu.Memory[0x3F0] = value
+		achievements.Update(emu)
+	}
+	if len(unlocked) != 1 || unlocked[0] != "Rich" {
+		t.Errorf("got: %v,but expected: [Rich]", unlocked)
+	}
+	emu.V[0xE] = 1
+	achievements.Update(emu)
+	if len(unlocked) != 2 || unlocked[1] != "Rich and alive" {
+		t.Errorf("got: %v,but expected: [Rich Rich and alive]", unlocked)
+	}
+
+	progress, err = LoadProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Unlocked("0000", "Rich") || progress.Unlocked("0000", "Poor") {
+		t.Errorf("got: %v,but expected: Rich unlocked only", progress.unlocked)
+	}
+}
+
+func TestAchievements_Brix(t *testing.T) {
+	rom, err := os.ReadFile("roms/BRIX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err := LoadProgress("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	achievements := NewAchievements(BuiltinAnnotations().Find(rom), progress)
+	var unlocked []string
+	achievements.OnUnlock = func(ach *Achievement) {
+		unlocked = append(unlocked, ach.Title)
+	}
+	emu := NewEmulator(NewFonts())
+	emu.Seed(1)
+	emu.OnFrame = func() {
+		achievements.Update(emu)
+	}
+	emu.Load(rom)
+	emu.Speed = 10
+	// follow the ball with the paddle
+	for i := 0; i < 3000; i++ {
+		var keys [16]bool
+		keys[4] = emu.V[0xC]+4 > emu.V[6]
+		keys[6] = emu.V[0xC]+4 < emu.V[6]
+		emu.SetKeys(keys)
+		emu.Frame()
+	}
+	if len(unlocked) < 2 || unlocked[0] != "First brick" || unlocked[len(unlocked)-1] != "Half the wall" {
+		t.Errorf("got: %v,but expected: First brick ... Half the wall", unlocked)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Edge kinds of the control-flow graph
+const (
+	EdgeNext = "next" // falls through to the next instruction
+	EdgeJump = "jump" // 1NNN
+	EdgeCall = "call" // 2NNN
+	EdgeSkip = "skip" // the instruction after a skipped one
+)
+
+// Edge is a control-flow edge between two blocks
+type Edge struct {
+	To   uint16 `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Block is a basic block, a run of instructions only entered at Start
+type Block struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"` // address of the last instruction
+	Edges []Edge `json:"edges,omitempty"`
+}
+
+// MemoryWrite is an FX33 or FX55 instruction writing into discovered code
+type MemoryWrite struct {
+	Pc   uint16 `json:"pc"`
+	Low  uint16 `json:"low"`
+	High uint16 `json:"high"`
+}
+
+// DataRange is a range of ROM bytes never reached as code
+type DataRange struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"` // inclusive
+}
+
+// Analysis is the result of the static analysis of a ROM
+type Analysis struct {
+	Blocks        []*Block      `json:"blocks"`
+	Subroutines   []uint16      `json:"subroutines"`
+	ComputedJumps []uint16      `json:"computed_jumps,omitempty"`
+	SelfModifying []MemoryWrite `json:"self_modifying,omitempty"`
+	Invalid       []uint16      `json:"invalid,omitempty"` // reached but not an instruction
+	Data          []DataRange   `json:"data,omitempty"`
+
+	memory [4096]uint8
+	code   [4096]bool // first byte of every reachable instruction
+	end    int        // end of the ROM in memory
+}
+
+// Analyze follows the jumps, calls and skips from 0x200 to discover the code of a ROM
+func Analyze(rom []byte) *Analysis {
+	a := &Analysis{end: 0x200 + len(rom)}
+	if a.end > len(a.memory) {
+		a.end = len(a.memory)
+	}
+	copy(a.memory[0x200:], rom)
+
+	leaders := map[uint16]bool{0x200: true}
+	subroutines := map[uint16]bool{}
+	work := []uint16{0x200}
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		if int(pc) < 0x200 || int(pc)+1 >= a.end || a.code[pc] {
+			continue
+		}
+		opcode := a.opcode(pc)
+		if !validOpcode(opcode) {
+			a.Invalid = append(a.Invalid, pc)
+			continue
+		}
+		a.code[pc] = true
+		for _, edge := range successors(pc, opcode) {
+			if edge.Kind != EdgeNext {
+				leaders[edge.To] = true
+			}
+			if edge.Kind == EdgeCall {
+				subroutines[edge.To] = true
+			}
+			work = append(work, edge.To)
+		}
+		if opcode&0xF000 == 0xB000 {
+			a.ComputedJumps = append(a.ComputedJumps, pc)
+		}
+		if isSkip(opcode) || opcode&0xF000 == 0x2000 {
+			leaders[pc+2] = true
+		}
+	}
+
+	for pc := 0x200; pc < a.end; pc++ {
+		if !a.code[pc] || !leaders[uint16(pc)] {
+			continue
+		}
+		block := &Block{Start: uint16(pc)}
+		for at := uint16(pc); ; at += 2 {
+			block.End = at
+			edges := successors(at, a.opcode(at))
+			next := at + 2
+			if len(edges) != 1 || edges[0].Kind != EdgeNext || !a.reached(next) || leaders[next] {
+				for _, edge := range edges {
+					if a.reached(edge.To) {
+						block.Edges = append(block.Edges, edge)
+					}
+				}
+				break
+			}
+		}
+		a.Blocks = append(a.Blocks, block)
+	}
+
+	for entry := range subroutines {
+		a.Subroutines = append(a.Subroutines, entry)
+	}
+	sort.Slice(a.Subroutines, func(i, j int) bool { return a.Subroutines[i] < a.Subroutines[j] })
+	sort.Slice(a.ComputedJumps, func(i, j int) bool { return a.ComputedJumps[i] < a.ComputedJumps[j] })
+	sort.Slice(a.Invalid, func(i, j int) bool { return a.Invalid[i] < a.Invalid[j] })
+	a.findSelfModifying()
+	a.findData()
+	return a
+}
+
+// reached reports whether an instruction was found at pc, false past the
+// end of the memory where the emulator stops
+func (a *Analysis) reached(pc uint16) bool {
+	return int(pc) < len(a.code) && a.code[pc]
+}
+
+func (a *Analysis) opcode(pc uint16) uint16 {
+	return uint16(a.memory[pc&0x0FFF])<<8 | uint16(a.memory[(pc+1)&0x0FFF])
+}
+
+// validOpcode reports whether an opcode is a CHIP-8 instruction the emulator executes
+func validOpcode(opcode uint16) bool {
+	return !strings.HasPrefix(Disassemble(opcode), "DW") && !strings.HasPrefix(Disassemble(opcode), "SYS")
+}
+
+// successors returns where the execution may continue after an instruction
+func successors(pc uint16, opcode uint16) []Edge {
+	switch {
+	case opcode == 0x00EE:
+		return nil
+	case opcode&0xF000 == 0x1000:
+		return []Edge{{To: opcode & 0x0FFF, Kind: EdgeJump}}
+	case opcode&0xF000 == 0x2000:
+		return []Edge{{To: opcode & 0x0FFF, Kind: EdgeCall}, {To: pc + 2, Kind: EdgeNext}}
+	case opcode&0xF000 == 0xB000:
+		return nil
+	case isSkip(opcode):
+		return []Edge{{To: pc + 2, Kind: EdgeNext}, {To: pc + 4, Kind: EdgeSkip}}
+	}
+	return []Edge{{To: pc + 2, Kind: EdgeNext}}
+}
+
+// findSelfModifying flags the FX33 and FX55 writing into code, following
+// the value of I from the ANNN instructions within each block
+func (a *Analysis) findSelfModifying() {
+	for _, block := range a.Blocks {
+		known := false
+		var i uint16
+		for pc := block.Start; pc <= block.End; pc += 2 {
+			opcode := a.opcode(pc)
+			switch {
+			case opcode&0xF000 == 0xA000:
+				known, i = true, opcode&0x0FFF
+			case opcode&0xF0FF == 0xF01E, opcode&0xF0FF == 0xF029, opcode&0xF0FF == 0xF065:
+				known = false
+			case opcode&0xF0FF == 0xF033, opcode&0xF0FF == 0xF055:
+				if !known {
+					continue
+				}
+				high := i + 2
+				if opcode&0xF0FF == 0xF055 {
+					high = i + opcode&0x0F00>>8
+				}
+				for at := i; at <= high; at++ {
+					if a.isCode(at) {
+						a.SelfModifying = append(a.SelfModifying, MemoryWrite{Pc: pc, Low: i, High: high})
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// isCode reports whether any byte of an instruction lies at addr
+func (a *Analysis) isCode(addr uint16) bool {
+	addr &= 0x0FFF
+	return a.code[addr] || (addr > 0 && a.code[addr-1])
+}
+
+func (a *Analysis) findData() {
+	for pc := 0x200; pc < a.end; pc++ {
+		if a.isCode(uint16(pc)) {
+			continue
+		}
+		if n := len(a.Data); n > 0 && int(a.Data[n-1].End) == pc-1 {
+			a.Data[n-1].End = uint16(pc)
+		} else {
+			a.Data = append(a.Data, DataRange{Start: uint16(pc), End: uint16(pc)})
+		}
+	}
+}
+
+// IsCode reports whether an instruction was found at addr
+func (a *Analysis) IsCode(addr uint16) bool {
+	return a.code[addr&0x0FFF]
+}
+
+// WriteText writes the disassembly of the blocks and a summary of the findings
+func (a *Analysis) WriteText(w io.Writer) {
+	subroutines := map[uint16]bool{}
+	for _, entry := range a.Subroutines {
+		subroutines[entry] = true
+	}
+	for _, block := range a.Blocks {
+		switch {
+		case block.Start == 0x200:
+			fmt.Fprintln(w, "main:")
+		case subroutines[block.Start]:
+			fmt.Fprintf(w, "\n%s:\n", subroutineName(block.Start))
+		default:
+			fmt.Fprintf(w, "L%03X:\n", block.Start)
+		}
+		for pc := block.Start; pc <= block.End; pc += 2 {
+			opcode := a.opcode(pc)
+			fmt.Fprintf(w, "    %03X  %04X  %s\n", pc, opcode, Disassemble(opcode))
+		}
+	}
+	fmt.Fprintln(w)
+	for _, d := range a.Data {
+		fmt.Fprintf(w, "data %03X-%03X (%d bytes)\n", d.Start, d.End, d.End-d.Start+1)
+	}
+	for _, pc := range a.ComputedJumps {
+		fmt.Fprintf(w, "computed jump at %03X: %s\n", pc, Disassemble(a.opcode(pc)))
+	}
+	for _, m := range a.SelfModifying {
+		fmt.Fprintf(w, "self-modifying write at %03X: %s writes %03X-%03X\n", m.Pc, Disassemble(a.opcode(m.Pc)), m.Low, m.High)
+	}
+	for _, pc := range a.Invalid {
+		fmt.Fprintf(w, "invalid instruction reached at %03X: %04X\n", pc, a.opcode(pc))
+	}
+}
+
+// WriteDot writes the control-flow graph in the Graphviz DOT language
+func (a *Analysis) WriteDot(w io.Writer) {
+	fmt.Fprintln(w, "digraph cfg {")
+	fmt.Fprintln(w, "  node [shape=box fontname=monospace];")
+	computed := map[uint16]bool{}
+	for _, pc := range a.ComputedJumps {
+		computed[pc] = true
+	}
+	for _, block := range a.Blocks {
+		var label strings.Builder
+		for pc := block.Start; pc <= block.End; pc += 2 {
+			fmt.Fprintf(&label, "%03X  %s\\l", pc, Disassemble(a.opcode(pc)))
+		}
+		style := ""
+		if computed[block.End] {
+			style = " color=red"
+		}
+		fmt.Fprintf(w, "  b%03X [label=\"%s\"%s];\n", block.Start, label.String(), style)
+		for _, edge := range block.Edges {
+			to := a.blockAt(edge.To)
+			fmt.Fprintf(w, "  b%03X -> b%03X [label=%s];\n", block.Start, to, edge.Kind)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// blockAt returns the start of the block containing pc
+func (a *Analysis) blockAt(pc uint16) uint16 {
+	i := sort.Search(len(a.Blocks), func(i int) bool { return a.Blocks[i].End >= pc })
+	if i < len(a.Blocks) && a.Blocks[i].Start <= pc {
+		return a.Blocks[i].Start
+	}
+	return pc
+}
+
+// WriteJSON writes the analysis as JSON
+func (a *Analysis) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// runAnalyze implements "chip8 analyze [flags] ROM"
+func runAnalyze(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text, dot or json")
+	output := flags.String("o", "", "write to `file` instead of the standard output")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: chip8 analyze [flags] ROM")
+	}
+	rom, err := ReadRom(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	a := Analyze(rom)
+	switch *format {
+	case "text":
+		a.WriteText(w)
+	case "dot":
+		a.WriteDot(w)
+	case "json":
+		return a.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	return nil
+}
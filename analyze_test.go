@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestAnalyze(t *testing.T) {
+	rom := []byte{
+		0xA2, 0x0C, // 200: LD I, 0x20C
+		0x22, 0x08, // 202: CALL 0x208
+		0x12, 0x02, // 204: JP 0x202
+		0xFF, 0xFF, // 206: data
+		0xF2, 0x55, // 208: LD [I], V2
+		0x00, 0xEE, // 20A: RET
+	}
+	a := Analyze(rom)
+	if len(a.Subroutines) != 1 || a.Subroutines[0] != 0x208 {
+		t.Errorf("got: %v,but expected: [0x208]", a.Subroutines)
+	}
+	if len(a.Data) != 1 || a.Data[0] != (DataRange{0x206, 0x207}) {
+		t.Errorf("got: %v,but expected: [{0x206 0x207}]", a.Data)
+	}
+	if len(a.Blocks) != 4 {
+		t.Errorf("got: %v blocks,but expected: 4", len(a.Blocks))
+	}
+	// I is not known at the entry of sub_208
+	if len(a.SelfModifying) != 0 {
+		t.Errorf("got: %v,but expected no self-modifying write", a.SelfModifying)
+	}
+
+	rom[8], rom[9] = 0xF3, 0x33 // 208: LD B, V3
+	rom = append(rom[:8], 0xA2, 0x0A, 0xF3, 0x33, 0x00, 0xEE)
+	a = Analyze(rom)
+	if len(a.SelfModifying) != 1 || a.SelfModifying[0].Pc != 0x20A {
+		t.Errorf("got: %v,but expected a self-modifying write at 0x20A", a.SelfModifying)
+	}
+}
+
+func TestAnalyze_EndOfMemory(t *testing.T) {
+	// a full ROM jumping to a skip at 0xFFC and an instruction at 0xFFE
+	rom := make([]byte, 4096-0x200)
+	copy(rom, []byte{0x1F, 0xFC})
+	copy(rom[0xFFC-0x200:], []byte{0x30, 0x00, 0x60, 0x01})
+	a := Analyze(rom)
+	if !a.IsCode(0xFFC) || !a.IsCode(0xFFE) {
+		t.Errorf("got: %v %v,but expected: the code at the end of the memory", a.IsCode(0xFFC), a.IsCode(0xFFE))
+	}
+	// the skip past the memory is not an edge
+	if skip := a.Blocks[len(a.Blocks)-2]; skip.Start != 0xFFC || len(skip.Edges) != 1 || skip.Edges[0].To != 0xFFE {
+		t.Errorf("got: %+v,but expected: a single edge to 0xFFE", skip)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Location is where a value lives: a V register or a memory address
+type Location struct {
+	Register bool
+	Addr     uint16 // register number or memory address
+}
+
+// ParseLocation parses "V0" to "VF" or a memory address such as "0x314"
+func ParseLocation(s string) (Location, error) {
+	if len(s) == 2 && (s[0] == 'V' || s[0] == 'v') {
+		reg, err := strconv.ParseUint(s[1:], 16, 4)
+		if err != nil {
+			return Location{}, fmt.Errorf("invalid register %q", s)
+		}
+		return Location{Register: true, Addr: uint16(reg)}, nil
+	}
+	addr, err := strconv.ParseUint(s, 0, 12)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid address %q", s)
+	}
+	return Location{Addr: uint16(addr)}, nil
+}
+
+// byteAt reads the i-th byte from the location
+func (l Location) byteAt(e *Emulator, i int) uint8 {
+	if l.Register {
+		return e.V[(int(l.Addr)+i)&0xF]
+	}
+	return e.Memory[(int(l.Addr)+i)&0x0FFF]
+}
+
+// Value types of the annotations
+const (
+	ValueByte     = "byte"     // a single byte
+	ValueBCD      = "bcd"      // Size bytes holding a decimal digit each, as written by FX33
+	ValueBitfield = "bitfield" // the bits of Mask in a byte
+)
+
+// Value describes a named value of a game
+type Value struct {
+	Location string `json:"location"`
+	Type     string `json:"type"`
+	Size     int    `json:"size,omitempty"` // digits of a bcd value, 3 by default
+	Mask     string `json:"mask,omitempty"` // bits of a bitfield value, such as "0x0F"
+
+	location Location
+	mask     uint8
+}
+
+// Read reads the value from the emulator
+func (v *Value) Read(e *Emulator) int {
+	switch v.Type {
+	case ValueBCD:
+		n := 0
+		for i := 0; i < v.Size; i++ {
+			n = n*10 + int(v.location.byteAt(e, i))
+		}
+		return n
+	case ValueBitfield:
+		return int(v.location.byteAt(e, 0)&v.mask) >> uint(bits.TrailingZeros8(v.mask))
+	default:
+		return int(v.location.byteAt(e, 0))
+	}
+}
+
+func (v *Value) compile() error {
+	var err error
+	if v.location, err = ParseLocation(v.Location); err != nil {
+		return err
+	}
+	switch v.Type {
+	case ValueByte:
+	case ValueBCD:
+		if v.Size == 0 {
+			v.Size = 3
+		}
+	case ValueBitfield:
+		mask, err := strconv.ParseUint(v.Mask, 0, 8)
+		if err != nil || mask == 0 {
+			return fmt.Errorf("invalid mask %q", v.Mask)
+		}
+		v.mask = uint8(mask)
+	default:
+		return fmt.Errorf("unknown type %q", v.Type)
+	}
+	return nil
+}
+
+// Annotation names the values of a game in memory and tells how to score it
+type Annotation struct {
+	Title  string            `json:"title"`
+	SHA1   string            `json:"sha1"`
+	Values map[string]*Value `json:"values"`
+	Score  string            `json:"score,omitempty"` // sum of values such as "left - right"
+	Over   []string          `json:"over,omitempty"`  // conditions ending the game, such as "lives == 0"
+
+	Achievements []*Achievement `json:"achievements,omitempty"`
+
+	score []scoreTerm
+	over  []*Condition
+}
+
+type scoreTerm struct {
+	value *Value
+	sign  int
+}
+
+// ParseAnnotation parses and checks an annotation file
+func ParseAnnotation(data []byte) (*Annotation, error) {
+	var a Annotation
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	a.SHA1 = strings.ToLower(a.SHA1)
+	for name, v := range a.Values {
+		if err := v.compile(); err != nil {
+			return nil, fmt.Errorf("%s: value %s: %v", a.Title, name, err)
+		}
+	}
+	sign := 1
+	for _, field := range strings.Fields(a.Score) {
+		switch field {
+		case "+":
+			sign = 1
+		case "-":
+			sign = -1
+		default:
+			v, ok := a.Values[field]
+			if !ok {
+				return nil, fmt.Errorf("%s: score: unknown value %q", a.Title, field)
+			}
+			a.score = append(a.score, scoreTerm{v, sign})
+		}
+	}
+	for _, s := range a.Over {
+		c, err := a.ParseCondition(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: over: %v", a.Title, err)
+		}
+		a.over = append(a.over, c)
+	}
+	for _, ach := range a.Achievements {
+		if err := ach.compile(&a); err != nil {
+			return nil, fmt.Errorf("%s: achievement %s: %v", a.Title, ach.Title, err)
+		}
+	}
+	return &a, nil
+}
+
+// Read reads a named value from the emulator
+func (a *Annotation) Read(e *Emulator, name string) (int, error) {
+	v, ok := a.Values[name]
+	if !ok {
+		return 0, fmt.Errorf("%s has no value %q", a.Title, name)
+	}
+	return v.Read(e), nil
+}
+
+// ReadAll reads every named value from the emulator
+func (a *Annotation) ReadAll(e *Emulator) map[string]int {
+	values := map[string]int{}
+	for name, v := range a.Values {
+		values[name] = v.Read(e)
+	}
+	return values
+}
+
+// Game returns the scoring of the annotated game, for Env
+func (a *Annotation) Game() Game {
+	g := Game{Name: a.Title}
+	if len(a.score) > 0 {
+		g.Score = func(e *Emulator) int {
+			score := 0
+			for _, t := range a.score {
+				score += t.sign * t.value.Read(e)
+			}
+			return score
+		}
+	}
+	if len(a.over) > 0 {
+		g.Over = func(e *Emulator) bool {
+			for _, c := range a.over {
+				if c.Eval(e) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return g
+}
+
+// Condition compares a named value with a number
+type Condition struct {
+	value *Value
+	op    string
+	arg   int
+}
+
+// ParseCondition parses a condition such as "lives == 0" over the values of
+// a, or over the byte of a location such as "0x3F0 >= 10" or "V5 > 3"
+func (a *Annotation) ParseCondition(s string) (*Condition, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid condition %q", s)
+	}
+	v, ok := a.Values[fields[0]]
+	if !ok {
+		location, err := ParseLocation(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("unknown value %q", fields[0])
+		}
+		v = &Value{Location: fields[0], Type: ValueByte, location: location}
+	}
+	switch fields[1] {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("invalid operator %q", fields[1])
+	}
+	arg, err := strconv.ParseInt(fields[2], 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", fields[2])
+	}
+	return &Condition{value: v, op: fields[1], arg: int(arg)}, nil
+}
+
+// Eval evaluates the condition on the emulator
+func (c *Condition) Eval(e *Emulator) bool {
+	v := c.value.Read(e)
+	switch c.op {
+	case "==":
+		return v == c.arg
+	case "!=":
+		return v != c.arg
+	case "<":
+		return v < c.arg
+	case "<=":
+		return v <= c.arg
+	case ">":
+		return v > c.arg
+	default:
+		return v >= c.arg
+	}
+}
+
+// RomHash returns the SHA-1 of a ROM as a hex string
+func RomHash(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Annotations are annotations by the SHA-1 of their ROM
+type Annotations map[string]*Annotation
+
+// Find returns the annotation of a ROM, nil if there is none
+func (as Annotations) Find(rom []byte) *Annotation {
+	return as[RomHash(rom)]
+}
+
+// LoadAnnotations reads the *.json annotation files of a directory
+func LoadAnnotations(dir string) (Annotations, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	as := Annotations{}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		a, err := ParseAnnotation(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+		as[a.SHA1] = a
+	}
+	return as, nil
+}
+
+//go:embed roms/annotations/*.json
+var annotationFiles embed.FS
+
+var builtinAnnotations struct {
+	once sync.Once
+	as   Annotations
+}
+
+// BuiltinAnnotations returns the annotations shipped in roms/annotations
+func BuiltinAnnotations() Annotations {
+	builtinAnnotations.once.Do(func() {
+		as := Annotations{}
+		entries, _ := annotationFiles.ReadDir("roms/annotations")
+		for _, entry := range entries {
+			data, _ := annotationFiles.ReadFile(path.Join("roms/annotations", entry.Name()))
+			a, err := ParseAnnotation(data)
+			if err != nil {
+				panic(fmt.Sprintf("%s: %v", entry.Name(), err))
+			}
+			as[a.SHA1] = a
+		}
+		builtinAnnotations.as = as
+	})
+	return builtinAnnotations.as
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotation_Read(t *testing.T) {
+	a, err := ParseAnnotation([]byte(`{
+		"title": "TEST",
+		"sha1": "0000",
+		"values": {
+			"lives": {"location": "VE", "type": "byte"},
+			"score": {"location": "0x300", "type": "bcd"},
+			"level": {"location": "0x303", "type": "bitfield", "mask": "0x70"}
+		},
+		"score": "score - lives",
+		"over": ["lives == 0"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := NewEmulator(NewFonts())
+	emu.V[0xE] = 2
+	copy(emu.Memory[0x300:], []byte{1, 2, 7, 0x35})
+	expected := map[string]int{"lives": 2, "score": 127, "level": 3}
+	for name, value := range a.ReadAll(emu) {
+		if value != expected[name] {
+			t.Errorf("got: %v = %v,but expected: %v", name, value, expected[name])
+		}
+	}
+	game := a.Game()
+	if actual := game.Score(emu); actual != 125 {
+		t.Errorf("got: %v,but expected: 125", actual)
+	}
+	if game.Over(emu) {
+		t.Errorf("got: over,but expected: not over")
+	}
+}
+
+func TestBuiltinAnnotations(t *testing.T) {
+	as := BuiltinAnnotations()
+	for _, path := range romPaths(t) {
+		rom, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := as.Find(rom); a != nil && a.Title != filepath.Base(path) {
+			t.Errorf("got: %v for %v,but expected: %v", a.Title, path, filepath.Base(path))
+		}
+	}
+	if a := as["f13766c14aeb02ad8d4d103cb5eadd282d20cddc"]; a == nil || a.Title != "BRIX" {
+		t.Errorf("got: %v,but expected: the BRIX annotation", a)
+	}
+}
+
+func TestBuiltinAnnotations_PongScore(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	if err := emu.LoadFile("roms/PONG"); err != nil {
+		t.Fatal(err)
+	}
+	a := BuiltinAnnotations().Find(emu.rom)
+	if a == nil {
+		t.Fatal("got: no annotation,but expected: the PONG annotation")
+	}
+	// sub_2D4 draws the digit at 0x2F3 at x=0x14, on the left, and the one
+	// at 0x2F4 at x=0x29, on the right
+	emu.Memory[0x2F3], emu.Memory[0x2F4] = 3, 1
+	if score := a.Game().Score(emu); score != 2 {
+		t.Errorf("got: %d,but expected: 2 with the left player leading", score)
+	}
+}
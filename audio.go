@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// SampleRate is the sample rate of the recorded sound, a whole number of
+// samples per 60Hz frame
+const SampleRate = 44100
+
+// samplesPerFrame is the number of samples of a frame
+const samplesPerFrame = SampleRate / 60
+
+// Beeper synthesizes the beep of the sound timer as a square wave
+type Beeper struct {
+	Frequency float64 // of the beep, in Hz
+	Volume    int16   // amplitude of the square wave
+	phase     float64 // from 0 to 1, kept between frames so the wave is continuous
+}
+
+// NewBeeper returns the usual 440Hz beep
+func NewBeeper() *Beeper {
+	return &Beeper{Frequency: 440, Volume: 8000}
+}
+
+// Frame returns the samples of a frame, the beep when on and silence otherwise
+func (b *Beeper) Frame(on bool) []int16 {
+	samples := make([]int16, samplesPerFrame)
+	if !on {
+		b.phase = 0
+		return samples
+	}
+	for i := range samples {
+		if b.phase < 0.5 {
+			samples[i] = b.Volume
+		} else {
+			samples[i] = -b.Volume
+		}
+		_, b.phase = math.Modf(b.phase + b.Frequency/SampleRate)
+	}
+	return samples
+}
+
+// WriteWAV writes mono 16 bits samples as a WAV file
+func WriteWAV(w io.Writer, rate int, samples []int16) error {
+	out := bufio.NewWriter(w)
+	size := uint32(2 * len(samples))
+	fields := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + size, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16),
+		uint16(1),        // PCM
+		uint16(1),        // mono
+		uint32(rate),     // samples per second
+		uint32(2 * rate), // bytes per second
+		uint16(2),        // bytes per sample
+		uint16(16),       // bits per sample
+		[4]byte{'d', 'a', 't', 'a'}, size,
+		samples,
+	}
+	for _, field := range fields {
+		if err := binary.Write(out, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// SoundTrack records the beep of an emulator frame by frame, to render it
+// deterministically whatever the speed of the run
+type SoundTrack struct {
+	beeping []bool // whether the sound timer was running, every frame
+}
+
+// Beep is a beep of a sound track, in frames
+type Beep struct {
+	Start, Frames int
+}
+
+// Capture records the current frame of an emulator
+func (s *SoundTrack) Capture(e *Emulator) {
+	s.beeping = append(s.beeping, e.beeping)
+}
+
+// Frames returns the number of frames recorded
+func (s *SoundTrack) Frames() int {
+	return len(s.beeping)
+}
+
+// Beeps lists the beeps of the sound track
+func (s *SoundTrack) Beeps() []Beep {
+	var beeps []Beep
+	for frame, on := range s.beeping {
+		switch {
+		case !on:
+		case len(beeps) > 0 && beeps[len(beeps)-1].Start+beeps[len(beeps)-1].Frames == frame:
+			beeps[len(beeps)-1].Frames++
+		default:
+			beeps = append(beeps, Beep{Start: frame, Frames: 1})
+		}
+	}
+	return beeps
+}
+
+// Samples renders the sound track at SampleRate
+func (s *SoundTrack) Samples() []int16 {
+	b := NewBeeper()
+	samples := make([]int16, 0, len(s.beeping)*samplesPerFrame)
+	for _, on := range s.beeping {
+		samples = append(samples, b.Frame(on)...)
+	}
+	return samples
+}
+
+// WriteFile renders the sound track into a WAV file
+func (s *SoundTrack) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteWAV(f, SampleRate, s.Samples()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
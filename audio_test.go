@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBeeper(t *testing.T) {
+	b := NewBeeper()
+	samples := b.Frame(true)
+	if len(samples) != 735 {
+		t.Errorf("got: %d,but expected: 735 samples per frame", len(samples))
+	}
+	// 44100/440 = 100.2 samples per period, half of them high
+	if samples[0] != b.Volume || samples[49] != b.Volume || samples[51] != -b.Volume {
+		t.Errorf("got: %v,but expected: a square wave", samples[:52])
+	}
+	for _, sample := range b.Frame(false) {
+		if sample != 0 {
+			t.Fatalf("got: %d,but expected: silence", sample)
+		}
+	}
+}
+
+func TestWriteWAV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, SampleRate, []int16{1, -1}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != 48 || string(data[:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Fatalf("got: %q,but expected: a WAV header", data)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != SampleRate {
+		t.Errorf("got: %d,but expected: %d", rate, SampleRate)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 4 || int16(binary.LittleEndian.Uint16(data[46:])) != -1 {
+		t.Errorf("got: %d bytes %v,but expected: 2 samples", size, data[44:])
+	}
+}
+
+// recordSound runs a ROM headless for 500 frames, recording the beep
+func recordSound(t *testing.T, rom string, path string) []Beep {
+	emu := NewEmulator(NewFonts())
+	emu.Seed(1)
+	if err := emu.LoadFile(rom); err != nil {
+		t.Fatal(err)
+	}
+	if err := emu.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := emu.RunHeadless(uint64(500 * emu.Speed)); err != nil {
+		t.Fatal(err)
+	}
+	beeps := emu.recorder.sound.Beeps()
+	if frames := emu.recorder.sound.Frames(); frames != 500 {
+		t.Errorf("got: %d,but expected: 500 frames", frames)
+	}
+	if err := emu.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	return beeps
+}
+
+func TestSoundTrack_Golden(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.wav"), filepath.Join(dir, "b.wav")}
+	// the ball bouncing on the paddles
+	expected := []Beep{{192, 32}, {386, 32}}
+	for _, path := range paths {
+		if beeps := recordSound(t, "roms/PONG", path); !reflect.DeepEqual(beeps, expected) {
+			t.Errorf("got: %v,but expected: %v", beeps, expected)
+		}
+	}
+	a, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 44+500*2*samplesPerFrame || !bytes.Equal(a, b) {
+		t.Errorf("got: %d and %d bytes,but expected: the same WAV files", len(a), len(b))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchResult is the outcome of running a ROM headlessly as fast as possible
+type BenchResult struct {
+	Instructions uint64
+	Elapsed      time.Duration
+	Allocs       uint64
+	Bytes        uint64
+}
+
+// MIPS returns the millions of emulated instructions per second
+func (r BenchResult) MIPS() float64 {
+	return float64(r.Instructions) / r.Elapsed.Seconds() / 1e6
+}
+
+// Bench runs a ROM headlessly for n instructions
+func Bench(path string, n uint64, cached bool) (BenchResult, error) {
+	emu := NewEmulator(NewFonts())
+	emu.UseCachedCore(cached)
+	emu.Seed(1)
+	if err := emu.LoadFile(path); err != nil {
+		return BenchResult{}, err
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := emu.RunHeadless(n)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return BenchResult{
+		Instructions: emu.Cycle,
+		Elapsed:      elapsed,
+		Allocs:       after.Mallocs - before.Mallocs,
+		Bytes:        after.TotalAlloc - before.TotalAlloc,
+	}, err
+}
+
+// runBench implements "chip8 bench [flags] [ROM...]"
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := flags.Uint64("n", 10000000, "number of instructions to execute per ROM")
+	cached := flags.Bool("cached", false, "use the cached decode core instead of the interpreter")
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		var err error
+		if paths, err = RomFiles("roms"); err != nil {
+			return err
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ROM\tinstructions\ttime\tMIPS\tallocs\tbytes\t")
+	var total BenchResult
+	for _, path := range paths {
+		r, err := Bench(path, *n, *cached)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%v\t%.2f\t%d\t%d\t\n", filepath.Base(path), r.Instructions,
+			r.Elapsed.Round(time.Millisecond), r.MIPS(), r.Allocs, r.Bytes)
+		total.Instructions += r.Instructions
+		total.Elapsed += r.Elapsed
+		total.Allocs += r.Allocs
+		total.Bytes += r.Bytes
+	}
+	fmt.Fprintf(w, "total\t%d\t%v\t%.2f\t%d\t%d\t\n", total.Instructions,
+		total.Elapsed.Round(time.Millisecond), total.MIPS(), total.Allocs, total.Bytes)
+	return w.Flush()
+}
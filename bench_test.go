@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkROM runs every ROM headlessly, b.N instructions at a time
+func BenchmarkROM(b *testing.B) {
+	for _, path := range romPaths(b) {
+		b.Run(filepath.Base(path), func(b *testing.B) {
+			emu := NewEmulator(NewFonts())
+			emu.Seed(1)
+			if err := emu.LoadFile(path); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			if err := emu.RunHeadless(uint64(b.N)); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// BenchmarkExec executes one opcode of every family
+func BenchmarkExec(b *testing.B) {
+	families := []uint16{
+		0x00E0, 0x00EE, 0x1200, 0x2200, 0x3012, 0x4012, 0x5010, 0x6012, 0x7012,
+		0x8010, 0x8011, 0x8012, 0x8013, 0x8014, 0x8015, 0x8016, 0x8017, 0x801E,
+		0x9010, 0xA300, 0xB200, 0xC0FF, 0xD015, 0xE09E, 0xE0A1, 0xF007, 0xF00A,
+		0xF015, 0xF018, 0xF01E, 0xF029, 0xF033, 0xF555, 0xF565,
+	}
+	for _, opcode := range families {
+		b.Run(Family(opcode), func(b *testing.B) {
+			emu := NewEmulator(NewFonts())
+			emu.Seed(1)
+			emu.Stack[0] = 0x200
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				emu.Pc = 0x200
+				emu.Sp = 1
+				emu.I = 0x300
+				emu.Exec(opcode)
+			}
+		})
+	}
+}
+
+func BenchmarkDraw(b *testing.B) {
+	emu := NewEmulator(NewFonts())
+	if err := emu.InitDisplay(); err != nil {
+		b.Skip(err)
+	}
+	defer emu.DestroyDisplay()
+	if err := emu.LoadFile("roms/BRIX"); err != nil {
+		b.Fatal(err)
+	}
+	if err := emu.RunHeadless(10000); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		emu.draw()
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+// decodeCache is the cached decode core: every address keeps its decoded
+// instruction until the program writes over it, so that hot loops are not
+// decoded again on every step
+type decodeCache struct {
+	instructions [4096]instruction
+	valid        [4096]bool
+}
+
+// UseCachedCore switches between the cached decode core and the interpreter.
+// Both execute the same instruction handlers.
+func (e *Emulator) UseCachedCore(enabled bool) {
+	if !enabled {
+		e.cache = nil
+	} else if e.cache == nil {
+		e.cache = &decodeCache{}
+	}
+}
+
+// lookup returns the instruction at pc, decoding it when it is not cached yet
+func (c *decodeCache) lookup(pc uint16, memory *[4096]uint8) instruction {
+	pc &= 0x0FFF
+	if !c.valid[pc] {
+		c.instructions[pc] = decode(uint16(memory[pc])<<8 | uint16(memory[(pc+1)&0x0FFF]))
+		c.valid[pc] = true
+	}
+	return c.instructions[pc]
+}
+
+// invalidate drops the instructions containing the byte at addr
+func (c *decodeCache) invalidate(addr uint16) {
+	c.valid[addr&0x0FFF] = false
+	c.valid[(addr-1)&0x0FFF] = false
+}
+
+// reset drops every instruction
+func (c *decodeCache) reset() {
+	c.valid = [4096]bool{}
+}
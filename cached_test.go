@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func romPaths(tb testing.TB) []string {
+	paths, err := RomFiles("roms")
+	if err != nil || len(paths) == 0 {
+		tb.Fatalf("no ROM found: %v", err)
+	}
+	return paths
+}
+
+func sameState(a, b *Emulator) bool {
+	return a.Pc == b.Pc && a.I == b.I && a.V == b.V && a.Sp == b.Sp && a.Stack == b.Stack &&
+		a.delayTimer == b.delayTimer && a.soundTimer == b.soundTimer &&
+		a.Memory == b.Memory && a.Gfx == b.Gfx
+}
+
+func TestCachedCore_BitIdentical(t *testing.T) {
+	for _, path := range romPaths(t) {
+		interpreter := NewEmulator(NewFonts())
+		cached := NewEmulator(NewFonts())
+		cached.UseCachedCore(true)
+		for _, emu := range []*Emulator{interpreter, cached} {
+			emu.Seed(1)
+			if err := emu.LoadFile(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 20000; i++ {
+			// press every key in turn to walk through more of the program
+			key := i / 500 % 16
+			interpreter.keys = [16]bool{}
+			cached.keys = [16]bool{}
+			interpreter.keys[key] = i%1000 < 500
+			cached.keys[key] = i%1000 < 500
+
+			if err := interpreter.Step(); err != nil {
+				t.Fatalf("%s: interpreter: %v", path, err)
+			}
+			interpreter.tickTimers()
+			if err := cached.Step(); err != nil {
+				t.Fatalf("%s: cached core: %v", path, err)
+			}
+			cached.tickTimers()
+			if !sameState(interpreter, cached) {
+				t.Fatalf("%s: state differs after %d steps at pc 0x%x", path, i+1, interpreter.Pc)
+			}
+		}
+	}
+}
+
+func benchmarkCore(b *testing.B, cached bool) {
+	emu := NewEmulator(NewFonts())
+	emu.UseCachedCore(cached)
+	emu.Seed(1)
+	if err := emu.LoadFile("roms/BRIX"); err != nil {
+		b.Fatal(err)
+	}
+	start := *emu
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%100000 == 0 {
+			b.StopTimer()
+			*emu = start
+			emu.UseCachedCore(false)
+			emu.UseCachedCore(cached)
+			b.StartTimer()
+		}
+		if err := emu.Step(); err != nil {
+			b.Fatal(err)
+		}
+		emu.tickTimers()
+	}
+}
+
+func BenchmarkInterpreter(b *testing.B) {
+	benchmarkCore(b, false)
+}
+
+func BenchmarkCachedCore(b *testing.B) {
+	benchmarkCore(b, true)
+}
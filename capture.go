@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Screenshot draws a display with a palette, each CHIP-8 pixel being a
+// square of scale pixels
+func Screenshot(gfx *[2048]uint8, palette Palette, scale int) *image.Paletted {
+	colors := make(color.Palette, len(palette))
+	for i, c := range palette {
+		colors[i] = color.RGBA{c.R, c.G, c.B, 255}
+	}
+	img := image.NewPaletted(image.Rect(0, 0, 64*scale, 32*scale), colors)
+	for y := 0; y < 32*scale; y++ {
+		for x := 0; x < 64*scale; x++ {
+			pixel := gfx[y/scale*64+x/scale]
+			if int(pixel) >= len(colors) {
+				pixel = uint8(len(colors) - 1)
+			}
+			img.Pix[img.PixOffset(x, y)] = pixel
+		}
+	}
+	return img
+}
+
+// SaveScreenshot saves the display as a PNG file, at the capture scale and
+// with the current palette
+func (e *Emulator) SaveScreenshot(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, Screenshot(&e.Gfx, e.Palette, e.CaptureScale)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// frameWriter writes the frames of a video file
+type frameWriter interface {
+	WriteFrame(gfx *[2048]uint8, palette Palette) error
+	Close() error
+}
+
+// gifWriter records an animated GIF, merging the identical frames. GIF
+// delays are in hundredths of a second and viewers slow down the delays
+// below 2, so a change of the display waits until the image shown has
+// lasted 2 hundredths. The delays follow the 60Hz frames, the rounding of
+// a delay being carried into the next one.
+type gifWriter struct {
+	file    *os.File
+	scale   int
+	anim    gif.GIF
+	last    *image.Paletted // the last frame recorded, which may not be shown yet
+	frames  int             // frames recorded
+	started int             // time the image shown started at, in hundredths of a second
+}
+
+// minGIFDelay is the shortest delay played as is by the viewers
+const minGIFDelay = 2
+
+func (w *gifWriter) WriteFrame(gfx *[2048]uint8, palette Palette) error {
+	img := Screenshot(gfx, palette, w.scale)
+	now := w.frames * 100 / 60
+	switch {
+	case len(w.anim.Image) == 0:
+		w.anim.Image = append(w.anim.Image, img)
+	case !samePaletted(img, w.anim.Image[len(w.anim.Image)-1]) && now-w.started >= minGIFDelay:
+		w.anim.Delay = append(w.anim.Delay, now-w.started)
+		w.anim.Image = append(w.anim.Image, img)
+		w.started = now
+	}
+	w.last = img
+	w.frames++
+	return nil
+}
+
+func (w *gifWriter) Close() error {
+	if n := len(w.anim.Image); n > 0 {
+		delay := w.frames*100/60 - w.started
+		if delay < minGIFDelay {
+			delay = minGIFDelay
+		}
+		w.anim.Delay = append(w.anim.Delay, delay)
+		// the last frame when it changed too soon to be shown
+		if !samePaletted(w.last, w.anim.Image[n-1]) {
+			w.anim.Image = append(w.anim.Image, w.last)
+			w.anim.Delay = append(w.anim.Delay, minGIFDelay)
+		}
+		if err := gif.EncodeAll(w.file, &w.anim); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+func samePaletted(a, b *image.Paletted) bool {
+	if len(a.Palette) != len(b.Palette) {
+		return false
+	}
+	for i := range a.Palette {
+		if a.Palette[i] != b.Palette[i] {
+			return false
+		}
+	}
+	return string(a.Pix) == string(b.Pix)
+}
+
+// y4mWriter streams raw frames in the YUV4MPEG2 format, which video tools
+// such as ffmpeg read
+type y4mWriter struct {
+	file   *os.File
+	out    *bufio.Writer
+	scale  int
+	header bool
+}
+
+func (w *y4mWriter) WriteFrame(gfx *[2048]uint8, palette Palette) error {
+	width, height := 64*w.scale, 32*w.scale
+	if !w.header {
+		fmt.Fprintf(w.out, "YUV4MPEG2 W%d H%d F60:1 Ip A1:1 C444\n", width, height)
+		w.header = true
+	}
+	// the Y, Cb and Cr planes
+	planes := make([][]byte, 3)
+	for i := range planes {
+		planes[i] = make([]byte, 0, width*height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := palette.Color(gfx[y/w.scale*64+x/w.scale])
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			planes[0] = append(planes[0], yy)
+			planes[1] = append(planes[1], cb)
+			planes[2] = append(planes[2], cr)
+		}
+	}
+	if _, err := w.out.WriteString("FRAME\n"); err != nil {
+		return err
+	}
+	for _, plane := range planes {
+		if _, err := w.out.Write(plane); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *y4mWriter) Close() error {
+	if err := w.out.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Recorder records the frames of an emulator into a GIF or Y4M file, and
+// the beep into a WAV file beside it, or only the beep into a WAV file
+type Recorder struct {
+	Path  string
+	video frameWriter // nil when recording the sound only
+	sound SoundTrack
+}
+
+// NewRecorder creates the recording, its format given by the extension of
+// path: .gif, .y4m or .wav
+func NewRecorder(path string, scale int) (*Recorder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".wav" {
+		return &Recorder{Path: path}, nil
+	}
+	if ext != ".gif" && ext != ".y4m" {
+		return nil, fmt.Errorf("cannot record %s, expected a .gif, .y4m or .wav file", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{Path: path}
+	if ext == ".gif" {
+		r.video = &gifWriter{file: f, scale: scale}
+	} else {
+		r.video = &y4mWriter{file: f, out: bufio.NewWriter(f), scale: scale}
+	}
+	return r, nil
+}
+
+// WAVPath returns the path of the WAV file of the beep
+func (r *Recorder) WAVPath() string {
+	return strings.TrimSuffix(r.Path, filepath.Ext(r.Path)) + ".wav"
+}
+
+// Capture records the current frame of an emulator
+func (r *Recorder) Capture(e *Emulator) error {
+	r.sound.Capture(e)
+	if r.video == nil {
+		return nil
+	}
+	return r.video.WriteFrame(&e.Gfx, e.Palette)
+}
+
+// Close finishes the video file and writes the WAV file
+func (r *Recorder) Close() error {
+	if r.video != nil {
+		if err := r.video.Close(); err != nil {
+			return err
+		}
+	}
+	return r.sound.WriteFile(r.WAVPath())
+}
+
+// StartRecording records the following frames into path, at the capture
+// scale, until StopRecording
+func (e *Emulator) StartRecording(path string) error {
+	if e.recorder != nil {
+		return fmt.Errorf("already recording %s", e.recorder.Path)
+	}
+	r, err := NewRecorder(path, e.CaptureScale)
+	if err != nil {
+		return err
+	}
+	e.recorder = r
+	return nil
+}
+
+// StopRecording finishes the recording, if any
+func (e *Emulator) StopRecording() error {
+	if e.recorder == nil {
+		return nil
+	}
+	r := e.recorder
+	e.recorder = nil
+	return r.Close()
+}
+
+// Recording reports whether the frames are being recorded
+func (e *Emulator) Recording() bool {
+	return e.recorder != nil
+}
+
+// captureName names the captures of the hotkeys after the game and the time
+func (e *Emulator) captureName(ext string) string {
+	name := "chip8"
+	if e.Rom != nil && e.Rom.Title != "" {
+		name = strings.ToLower(strings.Join(strings.Fields(e.Rom.Title), "-"))
+	}
+	return fmt.Sprintf("%s-%s%s", name, time.Now().Format("20060102-150405"), ext)
+}
+
+// toggleRecording starts recording into a new file with the extension ext,
+// or stops the recording
+func (e *Emulator) toggleRecording(ext string) {
+	if e.recorder != nil {
+		path := e.recorder.Path
+		if err := e.StopRecording(); err != nil {
+			log.Println(err)
+			e.Toast("Recording failed")
+			return
+		}
+		e.Toast("Saved " + filepath.Base(path))
+		return
+	}
+	if err := e.StartRecording(e.captureName(ext)); err != nil {
+		log.Println(err)
+		e.Toast("Recording failed")
+		return
+	}
+	e.Toast("Recording")
+}
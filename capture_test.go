@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmulator_SaveScreenshot(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	emu.Gfx[64+1] = 1
+	emu.CaptureScale = 2
+	path := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := emu.SaveScreenshot(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Errorf("got: %v,but expected: 128x64", b)
+	}
+	lit, background := DefaultPalette.Color(1), DefaultPalette.Color(0)
+	if r, g, b, _ := img.At(3, 3).RGBA(); uint8(r>>8) != lit.R || uint8(g>>8) != lit.G || uint8(b>>8) != lit.B {
+		t.Errorf("got: %v,but expected: the lit pixel at 1,1 scaled", img.At(3, 3))
+	}
+	if r, _, _, _ := img.At(4, 3).RGBA(); uint8(r>>8) != background.R {
+		t.Errorf("got: %v,but expected: the background", img.At(4, 3))
+	}
+}
+
+func TestRecorder_GIF(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	if err := emu.Load([]byte{0x12, 0x00}); err != nil { // jump to itself
+		t.Fatal(err)
+	}
+	emu.CaptureScale = 1
+	path := filepath.Join(t.TempDir(), "run.gif")
+	if err := emu.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	// 3 identical frames, then 3 others with the sound timer running
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			emu.Gfx[0] = 1
+			emu.soundTimer = 2
+		}
+		if err := emu.Frame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := emu.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 2 || anim.Delay[0] != 5 || anim.Delay[1] != 5 {
+		t.Errorf("got: %d images %v,but expected: 2 images of 5/100s", len(anim.Image), anim.Delay)
+	}
+
+	wav, err := os.ReadFile(filepath.Join(filepath.Dir(path), "run.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 44 + 6*samplesPerFrame*2; len(wav) != expected {
+		t.Errorf("got: %d bytes,but expected: %d", len(wav), expected)
+	}
+	silent, beeping := wav[44:44+2*samplesPerFrame], wav[44+3*2*samplesPerFrame:]
+	if !bytes.Equal(silent, make([]byte, len(silent))) || bytes.Equal(beeping[:2*samplesPerFrame], make([]byte, 2*samplesPerFrame)) {
+		t.Errorf("got: the beep in the wrong frames,but expected: it in the frames of the sound timer")
+	}
+}
+
+func TestGIFWriter_Delays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flicker.gif")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &gifWriter{file: f, scale: 1}
+	// a display changing every frame for a second
+	var gfx [2048]uint8
+	for i := 0; i < 60; i++ {
+		gfx[0] = uint8(i % 2)
+		gfx[1] = uint8(i / 2 % 2)
+		if err := w.WriteFrame(&gfx, DefaultPalette); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, delay := range w.anim.Delay {
+		if delay < minGIFDelay {
+			t.Errorf("got: a delay of %d,but expected: at least %d", delay, minGIFDelay)
+		}
+		total += delay
+	}
+	if total != 100 || len(w.anim.Delay) != len(w.anim.Image) {
+		t.Errorf("got: %d images lasting %d,but expected: a second", len(w.anim.Image), total)
+	}
+}
+
+func TestRecorder_Y4M(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.y4m")
+	r, err := NewRecorder(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := NewEmulator(NewFonts())
+	for i := 0; i < 2; i++ {
+		if err := r.Capture(emu); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := "YUV4MPEG2 W128 H64 F60:1 Ip A1:1 C444\n"
+	if expected := len(header) + 2*(len("FRAME\n")+3*128*64); !bytes.HasPrefix(data, []byte(header)) || len(data) != expected {
+		t.Errorf("got: %d bytes %q,but expected: %d bytes", len(data), data[:len(header)], expected)
+	}
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "run.mp4"), 1); err == nil {
+		t.Errorf("got: no error,but expected: an unsupported format error")
+	}
+}
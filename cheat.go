@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Search narrows down the addresses of a value in memory: every comparison
+// keeps the candidates matching it and takes a new snapshot of the memory
+type Search struct {
+	emu        *Emulator
+	snapshot   [4096]uint8
+	candidates []uint16
+}
+
+// NewSearch starts a search over the 4K of memory of an emulator
+func NewSearch(e *Emulator) *Search {
+	s := &Search{emu: e, snapshot: e.Memory}
+	for addr := range e.Memory {
+		s.candidates = append(s.candidates, uint16(addr))
+	}
+	return s
+}
+
+// filter keeps the candidates whose old and current byte satisfy keep
+func (s *Search) filter(keep func(old, cur uint8) bool) []uint16 {
+	kept := s.candidates[:0]
+	for _, addr := range s.candidates {
+		if keep(s.snapshot[addr], s.emu.Memory[addr]) {
+			kept = append(kept, addr)
+		}
+	}
+	s.candidates = kept
+	s.snapshot = s.emu.Memory
+	return kept
+}
+
+// Equal keeps the addresses holding value
+func (s *Search) Equal(value uint8) []uint16 {
+	return s.filter(func(old, cur uint8) bool { return cur == value })
+}
+
+// Changed keeps the addresses changed since the last snapshot
+func (s *Search) Changed() []uint16 {
+	return s.filter(func(old, cur uint8) bool { return cur != old })
+}
+
+// Unchanged keeps the addresses left alone since the last snapshot
+func (s *Search) Unchanged() []uint16 {
+	return s.filter(func(old, cur uint8) bool { return cur == old })
+}
+
+// Increased keeps the addresses increased since the last snapshot
+func (s *Search) Increased() []uint16 {
+	return s.filter(func(old, cur uint8) bool { return cur > old })
+}
+
+// Decreased keeps the addresses decreased since the last snapshot
+func (s *Search) Decreased() []uint16 {
+	return s.filter(func(old, cur uint8) bool { return cur < old })
+}
+
+// Results returns the addresses found so far
+func (s *Search) Results() []uint16 {
+	return s.candidates
+}
+
+// Cheat sets a register or a byte of memory to a value
+type Cheat struct {
+	Location Location
+	Value    uint8
+}
+
+// ParseCheat parses a cheat code such as "0x2F4=9" or "VE=3"
+func ParseCheat(s string) (Cheat, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return Cheat{}, fmt.Errorf("invalid cheat %q, expected LOCATION=VALUE", s)
+	}
+	location, err := ParseLocation(s[:i])
+	if err != nil {
+		return Cheat{}, err
+	}
+	value, err := strconv.ParseUint(s[i+1:], 0, 8)
+	if err != nil {
+		return Cheat{}, fmt.Errorf("invalid cheat value %q", s[i+1:])
+	}
+	return Cheat{Location: location, Value: uint8(value)}, nil
+}
+
+func (c Cheat) String() string {
+	if c.Location.Register {
+		return fmt.Sprintf("V%X=%d", c.Location.Addr, c.Value)
+	}
+	return fmt.Sprintf("0x%03X=%d", c.Location.Addr, c.Value)
+}
+
+// Apply sets the value once, as a patch applied on load
+func (c Cheat) Apply(e *Emulator) {
+	if c.Location.Register {
+		e.V[c.Location.Addr&0xF] = c.Value
+		return
+	}
+	addr := c.Location.Addr & 0x0FFF
+	e.Memory[addr] = c.Value
+	if e.cache != nil {
+		e.cache.invalidate(addr)
+	}
+}
+
+// Poke applies the cheat now and again after every reset
+func (e *Emulator) Poke(c Cheat) {
+	c.Apply(e)
+	e.pokes = append(e.pokes, c)
+}
+
+// Freeze applies a cheat at the end of every frame
+func (e *Emulator) Freeze(c Cheat) {
+	e.Unfreeze(c.Location)
+	e.frozen = append(e.frozen, c)
+}
+
+// Unfreeze stops applying the cheat of a location
+func (e *Emulator) Unfreeze(l Location) {
+	kept := e.frozen[:0]
+	for _, c := range e.frozen {
+		if c.Location != l {
+			kept = append(kept, c)
+		}
+	}
+	e.frozen = kept
+}
+
+// Frozen returns the cheats applied every frame
+func (e *Emulator) Frozen() []Cheat {
+	return e.frozen
+}
+
+// Cheats is a list of cheat codes given on the command line, see ParseCheat
+type Cheats []Cheat
+
+func (cs *Cheats) String() string {
+	var codes []string
+	for _, c := range *cs {
+		codes = append(codes, c.String())
+	}
+	return strings.Join(codes, ",")
+}
+
+// Set implements flag.Value, adding a code
+func (cs *Cheats) Set(s string) error {
+	c, err := ParseCheat(s)
+	if err != nil {
+		return err
+	}
+	*cs = append(*cs, c)
+	return nil
+}
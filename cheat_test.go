@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestSearch(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	// 200: LD I, 0x300; 202: LD V0, [I]; 204: ADD V0, 1; 206: LD [I], V0; 208: JP 0x200
+	copy(emu.Memory[0x200:], []byte{0xA3, 0x00, 0xF0, 0x65, 0x70, 0x01, 0xF0, 0x55, 0x12, 0x00})
+	emu.Speed = 5
+	search := NewSearch(emu)
+	emu.Frame()
+	if found := search.Increased(); len(found) != 1 || found[0] != 0x300 {
+		t.Errorf("got: %v,but expected: [768]", found)
+	}
+	if found := search.Decreased(); len(found) != 0 {
+		t.Errorf("got: %v,but expected: []", found)
+	}
+
+	search = NewSearch(emu)
+	emu.Frame()
+	if found := search.Equal(2); len(found) != 1 || found[0] != 0x300 {
+		t.Errorf("got: %v,but expected: [768]", found)
+	}
+	emu.Frame()
+	if found := search.Unchanged(); len(found) != 0 {
+		t.Errorf("got: %v,but expected: []", found)
+	}
+}
+
+func TestEmulator_Freeze(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	copy(emu.Memory[0x200:], []byte{0xA3, 0x00, 0xF0, 0x65, 0x70, 0x01, 0xF0, 0x55, 0x12, 0x00})
+	emu.Speed = 5
+	for _, code := range []string{"0x300=7", "V0=0x10"} {
+		c, err := ParseCheat(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emu.Freeze(c)
+	}
+	for i := 0; i < 3; i++ {
+		emu.Frame()
+		if emu.Memory[0x300] != 7 || emu.V[0] != 0x10 {
+			t.Errorf("got: 0x300=%d V0=%d,but expected: 0x300=7 V0=16", emu.Memory[0x300], emu.V[0])
+		}
+	}
+	emu.Unfreeze(Location{Addr: 0x300})
+	emu.Frame()
+	if emu.Memory[0x300] != 8 || len(emu.Frozen()) != 1 {
+		t.Errorf("got: 0x300=%d,but expected: 8", emu.Memory[0x300])
+	}
+
+	if _, err := ParseCheat("0x300"); err == nil {
+		t.Errorf("got: no error,but expected: an error for a code without value")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+// Speeds of the fast-forward and of the slow motion
+const (
+	turboFrames = 4 // frames run per displayed frame while fast-forwarding
+	slowFactor  = 4 // times a frame is displayed in slow motion
+)
+
+// Reset restarts the loaded ROM: it is copied again in memory and the
+// registers, timers, stack and display are cleared. The rest of the memory
+// and the settings are kept: quirks, speed, palette, cheats and keys, the
+// pokes being applied again.
+func (e *Emulator) Reset() {
+	copy(e.Memory[e.romStart:], e.rom)
+	e.Pc = e.romStart
+	e.Opcode, e.I, e.Sp = 0, 0, 0
+	e.V = [16]uint8{}
+	e.Stack = [16]uint16{}
+	e.Gfx = [2048]uint8{}
+	e.keys = [16]bool{}
+	e.delayTimer, e.soundTimer, e.beeping = 0, 0, false
+	e.err = nil
+	if e.cache != nil {
+		e.cache.reset()
+	}
+	for _, c := range e.pokes {
+		c.Apply(e)
+	}
+	e.shouldDraw = true
+}
+
+// HardReset restarts the machine as when powered on: the whole memory is
+// cleared before the fonts and the ROM are loaded again. The settings are kept.
+func (e *Emulator) HardReset() {
+	e.Memory = [4096]uint8{}
+	copy(e.Memory[:], e.fonts[:])
+	e.executed, e.written, e.fresh = [4096]bool{}, [4096]bool{}, [4096]bool{}
+	e.Reset()
+}
+
+// togglePause pauses or resumes the emulation
+func (e *Emulator) togglePause() {
+	e.paused = !e.paused
+	e.shouldDraw = true
+}
+
+// advance runs a single frame, pausing the emulation first
+func (e *Emulator) advance() {
+	e.paused = true
+	e.advancing = true
+	e.shouldDraw = true
+}
+
+// framesToRun returns the number of frames to run before the display is
+// drawn again
+func (e *Emulator) framesToRun() int {
+	switch {
+	case e.advancing:
+		e.advancing = false
+		return 1
+	case e.paused:
+		return 0
+	case e.turbo:
+		return turboFrames
+	}
+	return 1
+}
+
+// frameDelay returns the time a frame is displayed, in milliseconds
+func (e *Emulator) frameDelay() uint32 {
+	if e.slow && !e.turbo {
+		return slowFactor * 1000 / 60
+	}
+	return 1000 / 60
+}
+
+// status describes the state of the emulation shown over the display, ""
+// when running normally
+func (e *Emulator) status() string {
+	switch {
+	case e.paused:
+		return "Paused"
+	case e.turbo:
+		return "Fast forward"
+	case e.slow:
+		return "Slow motion"
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestEmulator_Reset(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	// V0 = 7, saved at 0x300 and over the ROM at 0x20A
+	rom := []byte{0x60, 0x07, 0xA3, 0x00, 0xF0, 0x55, 0xA2, 0x0A, 0xF0, 0x55, 0x00, 0xE0, 0x12, 0x0C}
+	if err := emu.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	emu.Speed = 1
+	emu.Quirks.IncrementI = true
+	emu.Poke(Cheat{Location{Addr: 0x400}, 9})
+	emu.Poke(Cheat{Location{Register: true, Addr: 0xE}, 3})
+	for i := 0; i < 7; i++ {
+		if err := emu.Frame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	emu.Memory[0] = 0xFF
+	emu.Gfx[0], emu.soundTimer = 1, 5
+
+	emu.Reset()
+	if emu.Pc != 0x200 || emu.V[0] != 0 || emu.I != 0 || emu.Gfx[0] != 0 || emu.soundTimer != 0 {
+		t.Errorf("got: pc %X V0 %d I %X,but expected: the registers cleared", emu.Pc, emu.V[0], emu.I)
+	}
+	if emu.Memory[0x20A] != 0x00 || emu.Memory[0x300] != 7 || emu.Memory[0] != 0xFF {
+		t.Errorf("got: %X %X %X,but expected: the ROM reloaded and the rest of the memory kept",
+			emu.Memory[0x20A], emu.Memory[0x300], emu.Memory[0])
+	}
+	if !emu.Quirks.IncrementI || emu.Speed != 1 {
+		t.Errorf("got: %+v %d,but expected: the settings kept", emu.Quirks, emu.Speed)
+	}
+	if emu.Memory[0x400] != 9 || emu.V[0xE] != 3 {
+		t.Errorf("got: %d %d,but expected: the pokes applied again", emu.Memory[0x400], emu.V[0xE])
+	}
+
+	emu.HardReset()
+	if emu.Memory[0x300] != 0 || emu.Memory[0] != NewFonts()[0] || emu.Memory[0x201] != 0x07 {
+		t.Errorf("got: %X %X,but expected: the memory cleared with the fonts and ROM loaded again",
+			emu.Memory[0x300], emu.Memory[0])
+	}
+	if emu.Memory[0x400] != 9 || emu.V[0xE] != 3 {
+		t.Errorf("got: %d %d,but expected: the pokes applied again", emu.Memory[0x400], emu.V[0xE])
+	}
+}
+
+func TestEmulator_FramesToRun(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	if emu.framesToRun() != 1 || emu.status() != "" {
+		t.Errorf("got: %d %q,but expected: a frame at a time", emu.framesToRun(), emu.status())
+	}
+	emu.turbo = true
+	if emu.framesToRun() != turboFrames || emu.frameDelay() != 1000/60 {
+		t.Errorf("got: %d frames,but expected: %d while fast-forwarding", emu.framesToRun(), turboFrames)
+	}
+	emu.turbo, emu.slow = false, true
+	if emu.frameDelay() != slowFactor*1000/60 || emu.status() != "Slow motion" {
+		t.Errorf("got: %dms,but expected: slow motion", emu.frameDelay())
+	}
+	emu.togglePause()
+	emu.advance()
+	if actual := []int{emu.framesToRun(), emu.framesToRun()}; actual[0] != 1 || actual[1] != 0 || emu.status() != "Paused" {
+		t.Errorf("got: %v,but expected: a single frame while paused", actual)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceLine is a line of assembler source
+type SourceLine struct {
+	File string
+	Line int
+}
+
+// SymbolMap maps instruction addresses to the source lines they were assembled from
+type SymbolMap map[uint16]SourceLine
+
+// LoadSymbolMap reads a symbol map made of lines such as
+//
+//	0x200 game.8o:12
+//
+// Empty lines and lines starting with # are ignored.
+func LoadSymbolMap(filepath string) (SymbolMap, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSymbolMap(file)
+}
+
+// ParseSymbolMap reads a symbol map, see LoadSymbolMap
+func ParseSymbolMap(r io.Reader) (SymbolMap, error) {
+	symbols := SymbolMap{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("symbol map line %d: expected address and file:line", n)
+		}
+		addr, err := strconv.ParseUint(fields[0], 0, 12)
+		if err != nil {
+			return nil, fmt.Errorf("symbol map line %d: %v", n, err)
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			return nil, fmt.Errorf("symbol map line %d: expected file:line", n)
+		}
+		lineNo, err := strconv.Atoi(fields[1][i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("symbol map line %d: %v", n, err)
+		}
+		symbols[uint16(addr)] = SourceLine{File: fields[1][:i], Line: lineNo}
+	}
+	return symbols, scanner.Err()
+}
+
+// Coverage records the executed addresses and the outcome of skip instructions.
+// It is a Tracer, so it is enabled with AddTracer.
+type Coverage struct {
+	Symbols  SymbolMap            // optional, to report source lines instead of addresses
+	counts   [4096]uint64         // executions per address
+	branches map[uint16][2]uint64 // per skip instruction: not skipped, skipped
+}
+
+// NewCoverage creates an empty Coverage
+func NewCoverage() *Coverage {
+	return &Coverage{branches: map[uint16][2]uint64{}}
+}
+
+// isSkip reports whether an opcode conditionally skips the next instruction
+func isSkip(opcode uint16) bool {
+	switch opcode & 0xF000 {
+	case 0x3000, 0x4000, 0x5000, 0x9000:
+		return true
+	case 0xE000:
+		return opcode&0x00FF == 0x9E || opcode&0x00FF == 0xA1
+	}
+	return false
+}
+
+// Trace records a record
+func (c *Coverage) Trace(r *TraceRecord) {
+	c.counts[r.Pc&0x0FFF]++
+	if isSkip(r.Opcode) {
+		b := c.branches[r.Pc]
+		if r.Next == r.Pc+4 {
+			b[1]++
+		} else {
+			b[0]++
+		}
+		c.branches[r.Pc] = b
+	}
+}
+
+// addresses returns the known code addresses in order: every address of the
+// symbol map, or without one the executed addresses and the instructions
+// following skips
+func (c *Coverage) addresses() []uint16 {
+	known := map[uint16]bool{}
+	if c.Symbols != nil {
+		for addr := range c.Symbols {
+			known[addr] = true
+		}
+	} else {
+		for addr, n := range c.counts {
+			if n > 0 {
+				known[uint16(addr)] = true
+			}
+		}
+		for addr := range c.branches {
+			known[addr+2] = true
+			known[addr+4] = true
+		}
+	}
+	var addrs []uint16
+	for addr := range known {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
+
+// source returns where an address comes from, the ROM itself without a symbol map
+func (c *Coverage) source(addr uint16, rom string) SourceLine {
+	if line, ok := c.Symbols[addr]; ok {
+		return line
+	}
+	return SourceLine{File: rom, Line: int(addr)}
+}
+
+// WriteLcov writes the coverage in the lcov tracefile format.
+// Without a symbol map the line numbers are the addresses in the ROM.
+func (c *Coverage) WriteLcov(w io.Writer, rom string) {
+	type lineCoverage struct {
+		count    uint64
+		branches [][2]uint64
+	}
+	files := map[string]map[int]*lineCoverage{}
+	for _, addr := range c.addresses() {
+		src := c.source(addr, rom)
+		if files[src.File] == nil {
+			files[src.File] = map[int]*lineCoverage{}
+		}
+		lc := files[src.File][src.Line]
+		if lc == nil {
+			lc = &lineCoverage{}
+			files[src.File][src.Line] = lc
+		}
+		lc.count += c.counts[addr]
+		if b, ok := c.branches[addr]; ok {
+			lc.branches = append(lc.branches, b)
+		}
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "TN:")
+	for _, name := range names {
+		lines := files[name]
+		var numbers []int
+		for n := range lines {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		fmt.Fprintf(w, "SF:%s\n", name)
+		hit, branchesFound, branchesHit := 0, 0, 0
+		for _, n := range numbers {
+			lc := lines[n]
+			for block, b := range lc.branches {
+				for branch, taken := range b {
+					if lc.count == 0 {
+						fmt.Fprintf(w, "BRDA:%d,%d,%d,-\n", n, block, branch)
+					} else {
+						fmt.Fprintf(w, "BRDA:%d,%d,%d,%d\n", n, block, branch, taken)
+					}
+					branchesFound++
+					if taken > 0 {
+						branchesHit++
+					}
+				}
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", branchesFound, branchesHit)
+		for _, n := range numbers {
+			fmt.Fprintf(w, "DA:%d,%d\n", n, lines[n].count)
+			if lines[n].count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(w, "LF:%d\nLH:%d\n", len(numbers), hit)
+		fmt.Fprintln(w, "end_of_record")
+	}
+}
+
+const coverageHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s coverage</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; }
+td { padding: 0 8px; white-space: pre; }
+tr.hit { background: #cfc; }
+tr.miss { background: #fcc; }
+tr.partial { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<p>%d of %d instructions executed, %d of %d skip outcomes taken</p>
+<table>
+`
+
+// WriteHTML writes the coverage as an HTML page listing the disassembly of e's
+// memory, or the source lines when a symbol map is present
+func (c *Coverage) WriteHTML(w io.Writer, rom string, e *Emulator) {
+	addrs := c.addresses()
+	hit, outcomes, taken := 0, 0, 0
+	for _, addr := range addrs {
+		if c.counts[addr] > 0 {
+			hit++
+		}
+		if b, ok := c.branches[addr]; ok {
+			outcomes += 2
+			for _, n := range b {
+				if n > 0 {
+					taken++
+				}
+			}
+		}
+	}
+	sources := map[string][]string{}
+	title := html.EscapeString(rom)
+	fmt.Fprintf(w, coverageHTMLHeader, title, title, hit, len(addrs), taken, outcomes)
+
+	for _, addr := range addrs {
+		class := "miss"
+		if c.counts[addr] > 0 {
+			class = "hit"
+			if b, ok := c.branches[addr]; ok && (b[0] == 0 || b[1] == 0) {
+				class = "partial"
+			}
+		}
+		branch := ""
+		if b, ok := c.branches[addr]; ok {
+			branch = fmt.Sprintf("next %d, skipped %d", b[0], b[1])
+		}
+		where := ""
+		if src, ok := c.Symbols[addr]; ok {
+			where = fmt.Sprintf("%s:%d", src.File, src.Line)
+			if text := sourceText(sources, src); text != "" {
+				where += "  " + text
+			}
+		}
+		opcode := e.opcodeAt(addr)
+		fmt.Fprintf(w, "<tr class=\"%s\"><td>%d</td><td>%03X</td><td>%04X</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			class, c.counts[addr], addr, opcode, html.EscapeString(Disassemble(opcode)),
+			branch, html.EscapeString(where))
+	}
+	fmt.Fprintln(w, "</table>\n</body>\n</html>")
+}
+
+// sourceText returns a line of an assembler source file, if it can be read
+func sourceText(sources map[string][]string, src SourceLine) string {
+	lines, ok := sources[src.File]
+	if !ok {
+		data, err := os.ReadFile(src.File)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		sources[src.File] = lines
+	}
+	if src.Line < 1 || src.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[src.Line-1])
+}
+
+// WriteFile writes the coverage report of rom to a file in the given format
+func (c *Coverage) WriteFile(filepath string, format string, rom string, e *Emulator) error {
+	if format != "lcov" && format != "html" {
+		return fmt.Errorf("unknown coverage format %q", format)
+	}
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if format == "html" {
+		c.WriteHTML(w, rom, e)
+	} else {
+		c.WriteLcov(w, rom)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
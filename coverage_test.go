@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCoverage_Lcov(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	// 200: SE V0, 0x00; 202: LD V1, 0x01; 204: LD V2, 0x02; 206: JP 0x206
+	copy(emu.Memory[0x200:], []byte{0x30, 0x00, 0x61, 0x01, 0x62, 0x02, 0x12, 0x06})
+	symbols, err := ParseSymbolMap(strings.NewReader("0x200 test.8o:1\n0x202 test.8o:2\n0x204 test.8o:3\n0x206 test.8o:4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage := NewCoverage()
+	coverage.Symbols = symbols
+	emu.AddTracer(coverage)
+	for i := 0; i < 3; i++ {
+		emu.Step()
+	}
+
+	var buf bytes.Buffer
+	coverage.WriteLcov(&buf, "TEST")
+	actual := buf.String()
+	for _, expected := range []string{"SF:test.8o", "DA:1,1", "DA:2,0", "DA:3,1", "DA:4,1", "BRDA:1,0,0,0", "BRDA:1,0,1,1", "LH:3"} {
+		if !strings.Contains(actual, expected+"\n") {
+			t.Errorf("got:\n%s\nbut expected it to contain %s", actual, expected)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"github.com/veandco/go-sdl2/sdl"
+	"log"
+)
+
+// Layout of the debugger, in pixels of a canvas scaled into its window
+const (
+	debuggerWidth      = 680
+	debuggerHeight     = 380
+	debuggerFont       = 2
+	debuggerRow        = (glyphHeight + 1) * debuggerFont
+	debuggerLines      = 21 // instructions around the program counter
+	debuggerRegistersX = 264
+	debuggerMemoryX    = 424
+	memoryRows         = 16
+	memoryColumns      = 8
+	spriteScale        = 6 // pixels per sprite pixel
+	searchResults      = 6 // addresses shown by the search
+)
+
+// Colors of the debugger
+var (
+	debuggerText      = Color{200, 200, 200}
+	debuggerDim       = Color{110, 110, 110}
+	debuggerTitle     = Color{120, 160, 255}
+	debuggerHighlight = Color{50, 50, 110}
+	debuggerWritten   = Color{255, 150, 50}
+	debuggerIndex     = Color{100, 220, 220}
+)
+
+// Debugger is a window showing the code, registers, call stack, memory and
+// sprites of an emulator while it runs, with a search of the memory. It
+// traces the instructions to highlight the bytes written recently.
+type Debugger struct {
+	window     *sdl.Window
+	renderer   *sdl.Renderer
+	canvas     *sdl.Texture
+	id         uint32       // of the window, to tell its events
+	written    [4096]uint64 // Cycle after the last write of each byte, 0 when never written
+	memoryTop  uint16       // first address of the memory view
+	followI    bool         // the memory view follows I until scrolled
+	spriteRows int          // height of the sprite at I
+	search     *Search      // nil until a search is started
+}
+
+// debugLine is a line of text of the debugger
+type debugLine struct {
+	text      string
+	color     Color
+	highlight bool
+}
+
+// NewDebugger creates a debugger, without its window
+func NewDebugger() *Debugger {
+	return &Debugger{followI: true, spriteRows: 15}
+}
+
+// Trace implements Tracer, recording the memory writes
+func (d *Debugger) Trace(r *TraceRecord) {
+	for _, w := range r.Writes {
+		d.written[w.Addr] = r.Cycle + 1
+	}
+}
+
+// Disassembly returns the instructions around the program counter, those
+// never executed dimmed as they may be data
+func (d *Debugger) Disassembly(e *Emulator) []debugLine {
+	start := int(e.Pc) - 2*(debuggerLines/2)
+	if start < 0 {
+		start = int(e.Pc) % 2
+	}
+	var lines []debugLine
+	for addr := start; addr+1 < len(e.Memory) && len(lines) < debuggerLines; addr += 2 {
+		opcode := uint16(e.Memory[addr])<<8 | uint16(e.Memory[addr+1])
+		line := debugLine{text: fmt.Sprintf("  %03X %04X %s", addr, opcode, Disassemble(opcode)), color: debuggerText}
+		if !e.executed[addr] {
+			line.color = debuggerDim
+		}
+		if addr == int(e.Pc) {
+			line.text = ">" + line.text[1:]
+			line.highlight = true
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Registers returns the registers, then the call stack, the last call first
+func (d *Debugger) Registers(e *Emulator) []string {
+	var lines []string
+	for x := 0; x < 16; x += 2 {
+		lines = append(lines, fmt.Sprintf("V%X %02X  V%X %02X", x, e.V[x], x+1, e.V[x+1]))
+	}
+	lines = append(lines,
+		fmt.Sprintf("I  %03X", e.I),
+		fmt.Sprintf("PC %03X  SP %X", e.Pc, e.Sp),
+		fmt.Sprintf("DT %02X  ST %02X", e.delayTimer, e.soundTimer),
+		"",
+		"STACK",
+	)
+	for i := int(e.Sp) - 1; i >= 0 && i < len(e.Stack); i-- {
+		lines = append(lines, fmt.Sprintf("%X  %03X", i, e.Stack[i]))
+	}
+	return lines
+}
+
+// scrollMemory moves the memory view by a number of rows
+func (d *Debugger) scrollMemory(rows int) {
+	top := int(d.memoryTop) + rows*memoryColumns
+	if top < 0 {
+		top = 0
+	}
+	if max := 4096 - memoryRows*memoryColumns; top > max {
+		top = max
+	}
+	d.memoryTop, d.followI = uint16(top), false
+}
+
+// memoryStart returns the first address of the memory view, two rows
+// before I when following it
+func (d *Debugger) memoryStart(e *Emulator) uint16 {
+	if !d.followI {
+		return d.memoryTop
+	}
+	top := int(e.I&^(memoryColumns-1)) - 2*memoryColumns
+	if top < 0 {
+		top = 0
+	}
+	if max := 4096 - memoryRows*memoryColumns; top > max {
+		top = max
+	}
+	return uint16(top)
+}
+
+// recentlyWritten reports whether a byte was written during the last second
+func (d *Debugger) recentlyWritten(e *Emulator, addr uint16) bool {
+	return d.written[addr] != 0 && e.Cycle-d.written[addr] < uint64(60*e.Speed)
+}
+
+// Sprite returns the rows of the sprite at I
+func (d *Debugger) Sprite(e *Emulator) []uint8 {
+	var rows []uint8
+	for i := 0; i < d.spriteRows; i++ {
+		rows = append(rows, e.Memory[(int(e.I)+i)&0x0FFF])
+	}
+	return rows
+}
+
+// SearchLines returns the number of addresses found by the search and the
+// first of them with their value
+func (d *Debugger) SearchLines(e *Emulator) []string {
+	if d.search == nil {
+		return []string{"N TO START"}
+	}
+	results := d.search.Results()
+	lines := []string{fmt.Sprintf("%d FOUND", len(results))}
+	for i := 0; i < len(results) && i < searchResults; i++ {
+		lines = append(lines, fmt.Sprintf("%03X  %02X", results[i], e.Memory[results[i]]))
+	}
+	return lines
+}
+
+// searchKey narrows the search with a key: M keeps the addresses changed
+// since the last key, U the unchanged ones, I the increased ones and K the
+// decreased ones. N starts a new search and Return shows the first
+// address in the memory view.
+func (d *Debugger) searchKey(e *Emulator, key sdl.Scancode) {
+	if key == sdl.SCANCODE_N {
+		d.search = NewSearch(e)
+		return
+	}
+	if d.search == nil {
+		return
+	}
+	switch key {
+	case sdl.SCANCODE_M:
+		d.search.Changed()
+	case sdl.SCANCODE_U:
+		d.search.Unchanged()
+	case sdl.SCANCODE_I:
+		d.search.Increased()
+	case sdl.SCANCODE_K:
+		d.search.Decreased()
+	case sdl.SCANCODE_RETURN:
+		if results := d.search.Results(); len(results) > 0 {
+			d.memoryTop, d.followI = results[0]&^(memoryColumns-1), false
+			d.scrollMemory(0)
+		}
+	}
+}
+
+func (d *Debugger) draw(e *Emulator) {
+	r := d.renderer
+	r.SetRenderTarget(d.canvas)
+	fillRect(r, sdl.Rect{X: 0, Y: 0, W: debuggerWidth, H: debuggerHeight}, Color{20, 20, 20}, 255)
+
+	x, y := int32(8), int32(6)
+	drawText(r, x, y, debuggerFont, "CODE", debuggerTitle)
+	for _, line := range d.Disassembly(e) {
+		y += debuggerRow
+		if line.highlight {
+			fillRect(r, sdl.Rect{X: x - 4, Y: y - 2, W: debuggerRegistersX - 16, H: debuggerRow}, debuggerHighlight, 255)
+		}
+		drawText(r, x, y, debuggerFont, line.text, line.color)
+	}
+
+	y += 2 * debuggerRow
+	drawText(r, x, y, debuggerFont, "SEARCH", debuggerTitle)
+	for _, line := range d.SearchLines(e) {
+		y += debuggerRow
+		drawText(r, x, y, debuggerFont, line, debuggerText)
+	}
+
+	x, y = debuggerRegistersX, 6
+	drawText(r, x, y, debuggerFont, "REGISTERS", debuggerTitle)
+	for _, line := range d.Registers(e) {
+		y += debuggerRow
+		color := debuggerText
+		if line == "STACK" {
+			color = debuggerTitle
+		}
+		drawText(r, x, y, debuggerFont, line, color)
+	}
+
+	x, y = debuggerMemoryX, 6
+	start := d.memoryStart(e)
+	drawText(r, x, y, debuggerFont, "MEMORY", debuggerTitle)
+	for row := 0; row < memoryRows; row++ {
+		y += debuggerRow
+		addr := start + uint16(row*memoryColumns)
+		drawText(r, x, y, debuggerFont, fmt.Sprintf("%03X", addr), debuggerDim)
+		for col := 0; col < memoryColumns; col++ {
+			a := addr + uint16(col)
+			color := debuggerText
+			switch {
+			case d.recentlyWritten(e, a):
+				color = debuggerWritten
+			case a >= e.I && int(a) < int(e.I)+d.spriteRows:
+				color = debuggerIndex
+			}
+			drawText(r, x+int32(5+3*col)*glyphWidth*debuggerFont, y, debuggerFont, fmt.Sprintf("%02X", e.Memory[a]), color)
+		}
+	}
+
+	y += 2 * debuggerRow
+	drawText(r, x, y, debuggerFont, fmt.Sprintf("SPRITE AT %03X, 8X%d", e.I, d.spriteRows), debuggerTitle)
+	y += debuggerRow
+	for row, bits := range d.Sprite(e) {
+		for col := int32(0); col < 8; col++ {
+			color := Color{40, 40, 40}
+			if bits&(0x80>>uint(col)) != 0 {
+				color = debuggerText
+			}
+			rect := sdl.Rect{X: x + col*spriteScale, Y: y + int32(row)*spriteScale, W: spriteScale - 1, H: spriteScale - 1}
+			fillRect(r, rect, color, 255)
+		}
+	}
+
+	present(r, d.canvas, debuggerWidth, debuggerHeight, windowArea(r))
+	r.Present()
+}
+
+// handleEvent handles the events of the debugger window, reporting whether
+// the event was for it. Up, Down, Page Up and Page Down scroll the memory,
+// Home follows I again, - and = change the height of the sprite, and the
+// keys of searchKey search the memory.
+func (d *Debugger) handleEvent(e *Emulator, ev sdl.Event) (handled bool, closed bool) {
+	switch et := ev.(type) {
+	case *sdl.WindowEvent:
+		if et.WindowID != d.id {
+			return false, false
+		}
+		return true, et.Event == sdl.WINDOWEVENT_CLOSE
+	case *sdl.KeyboardEvent:
+		if et.WindowID != d.id || et.Type != sdl.KEYDOWN {
+			return false, false
+		}
+		switch et.Keysym.Scancode {
+		case sdl.SCANCODE_UP:
+			d.scrollMemory(-1)
+		case sdl.SCANCODE_DOWN:
+			d.scrollMemory(1)
+		case sdl.SCANCODE_PAGEUP:
+			d.scrollMemory(-memoryRows)
+		case sdl.SCANCODE_PAGEDOWN:
+			d.scrollMemory(memoryRows)
+		case sdl.SCANCODE_HOME:
+			d.followI = true
+		case sdl.SCANCODE_MINUS:
+			if d.spriteRows > 1 {
+				d.spriteRows--
+			}
+		case sdl.SCANCODE_EQUALS:
+			if d.spriteRows < 15 {
+				d.spriteRows++
+			}
+		case sdl.SCANCODE_N, sdl.SCANCODE_M, sdl.SCANCODE_U, sdl.SCANCODE_I, sdl.SCANCODE_K, sdl.SCANCODE_RETURN:
+			d.searchKey(e, et.Keysym.Scancode)
+		default:
+			// the hotkeys of the emulator work in both windows
+			return false, false
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// openDebugger opens the debugger window beside the display
+func (e *Emulator) openDebugger() error {
+	d := NewDebugger()
+	window, err := sdl.CreateWindow("CHIP-8 debugger", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		debuggerWidth, debuggerHeight, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
+	if err != nil {
+		return err
+	}
+	d.window = window
+	if d.id, err = window.GetID(); err != nil {
+		window.Destroy()
+		return err
+	}
+	if d.renderer, err = sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_TARGETTEXTURE); err != nil {
+		window.Destroy()
+		return err
+	}
+	d.canvas, err = d.renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_TARGET, debuggerWidth, debuggerHeight)
+	if err != nil {
+		d.renderer.Destroy()
+		window.Destroy()
+		return err
+	}
+	d.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	e.debugger = d
+	e.AddTracer(d)
+	// keep the keyboard on the game
+	e.window.Raise()
+	return nil
+}
+
+// closeDebugger closes the debugger window, if open
+func (e *Emulator) closeDebugger() {
+	d := e.debugger
+	if d == nil {
+		return
+	}
+	e.RemoveTracer(d)
+	e.debugger = nil
+	d.canvas.Destroy()
+	d.renderer.Destroy()
+	d.window.Destroy()
+}
+
+// toggleDebugger opens or closes the debugger window
+func (e *Emulator) toggleDebugger() {
+	if e.debugger != nil {
+		e.closeDebugger()
+		return
+	}
+	if err := e.openDebugger(); err != nil {
+		log.Println(err)
+		e.Toast("Debugger failed")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"strings"
+	"testing"
+)
+
+func TestDebugger(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	// call 0x206 which sets I to the font of 0, stores V0 at 0x300 and loops
+	rom := []byte{0x22, 0x06, 0x00, 0x00, 0x00, 0x00, 0xA0, 0x00, 0x60, 0x2A, 0xA3, 0x00, 0xF0, 0x55, 0x12, 0x0E}
+	if err := emu.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	emu.Speed = 1
+	d := NewDebugger()
+	emu.AddTracer(d)
+	for i := 0; i < 6; i++ {
+		if err := emu.Frame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := d.Disassembly(emu)
+	if len(lines) != debuggerLines || lines[3].text != "  200 2206 CALL 0x206" {
+		t.Fatalf("got: %d lines with %q,but expected: %d with 0x200", len(lines), lines[3].text, debuggerLines)
+	}
+	if pc := lines[debuggerLines/2]; !pc.highlight || !strings.HasPrefix(pc.text, "> 20E 120E") {
+		t.Errorf("got: %+v,but expected: the program counter in the middle", pc)
+	}
+	if lines[4].color != debuggerDim || lines[3].color != debuggerText {
+		t.Errorf("got: %v %v,but expected: the bytes never executed dimmed", lines[3].color, lines[4].color)
+	}
+
+	registers := d.Registers(emu)
+	if registers[0] != "V0 2A  V1 00" || registers[len(registers)-1] != "0  200" {
+		t.Errorf("got: %q,but expected: V0 and the call on the stack", registers)
+	}
+	if !d.recentlyWritten(emu, 0x300) || d.recentlyWritten(emu, 0x301) {
+		t.Errorf("got: %v,but expected: 0x300 written recently", d.written[0x300])
+	}
+	emu.RemoveTracer(d)
+	if len(emu.tracers) != 0 {
+		t.Errorf("got: %d tracers,but expected: none", len(emu.tracers))
+	}
+
+	if start := d.memoryStart(emu); start != 0x2F0 {
+		t.Errorf("got: %X,but expected: the memory view following I", start)
+	}
+	d.scrollMemory(-1000)
+	if start := d.memoryStart(emu); start != 0 {
+		t.Errorf("got: %X,but expected: 0", start)
+	}
+	d.scrollMemory(1000)
+	if start := d.memoryStart(emu); start != 4096-memoryRows*memoryColumns {
+		t.Errorf("got: %X,but expected: the last page", start)
+	}
+
+	emu.I = 0
+	d.spriteRows = 5
+	fonts := NewFonts()
+	if sprite := d.Sprite(emu); string(sprite) != string(fonts[:5]) {
+		t.Errorf("got: %X,but expected: the font of 0", sprite)
+	}
+
+	if lines := d.SearchLines(emu); len(lines) != 1 {
+		t.Errorf("got: %q,but expected: no search yet", lines)
+	}
+	d.searchKey(emu, sdl.SCANCODE_N)
+	emu.Memory[0x300]++
+	d.searchKey(emu, sdl.SCANCODE_I)
+	if lines := d.SearchLines(emu); len(lines) != 2 || lines[0] != "1 FOUND" || lines[1] != "300  2B" {
+		t.Errorf("got: %q,but expected: 0x300 increased", lines)
+	}
+	d.searchKey(emu, sdl.SCANCODE_RETURN)
+	if start := d.memoryStart(emu); start != 0x300 {
+		t.Errorf("got: %X,but expected: the memory view at the address found", start)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Detection is the platform and quirks profile guessed for a ROM
+type Detection struct {
+	Platform   string
+	Quirks     string
+	Confidence float64  // from 0 to 1
+	Reasons    []string // the evidence found in the ROM
+}
+
+// maxVipRom is the largest program fitting in the 4K COSMAC VIP, whose
+// interpreter keeps 0xEA0-0xFFF for itself
+const maxVipRom = 0xEA0 - 0x200
+
+// schipOpcode reports whether an opcode is an instruction of SCHIP only
+func schipOpcode(opcode uint16) bool {
+	switch {
+	case opcode == 0x00FB, opcode == 0x00FC, opcode == 0x00FD, opcode == 0x00FE, opcode == 0x00FF,
+		opcode&0xFFF0 == 0x00C0, opcode&0xF0FF == 0xF030, opcode&0xF0FF == 0xF075, opcode&0xF0FF == 0xF085:
+		return true
+	}
+	return false
+}
+
+// xochipOpcode reports whether an opcode is an instruction of XO-CHIP only
+func xochipOpcode(opcode uint16) bool {
+	switch {
+	case opcode == 0xF000, opcode == 0xF002, opcode&0xF0FF == 0xF001, opcode&0xF0FF == 0xF03A,
+		opcode&0xF00F == 0x5002, opcode&0xF00F == 0x5003:
+		return true
+	}
+	return false
+}
+
+// DetectPlatform guesses the platform of a ROM from its instructions and
+// its size. The instructions reached from 0x200 by Analyze weigh more than
+// the words merely found at even offsets, which are most often sprites and
+// only count when computed jumps hide some code from the analysis.
+func DetectPlatform(rom []byte) Detection {
+	a := Analyze(rom)
+	reached := map[uint16]bool{}
+	for _, pc := range a.Invalid {
+		reached[pc] = true
+	}
+	var schip, xochip, vip, schipScanned, xochipScanned int
+	for pc := 0x200; pc+1 < 0x200+len(rom); pc += 2 {
+		opcode := a.opcode(uint16(pc))
+		switch {
+		case xochipOpcode(opcode) && reached[uint16(pc)]:
+			xochip++
+		case xochipOpcode(opcode):
+			xochipScanned++
+		case schipOpcode(opcode) && reached[uint16(pc)]:
+			schip++
+		case schipOpcode(opcode):
+			schipScanned++
+		case opcode&0xF00F == 0xD000 && a.IsCode(uint16(pc)):
+			schip++
+		case (opcode&0xF00F == 0x8006 || opcode&0xF00F == 0x800E) && opcode&0x0F00>>8 != opcode&0x00F0>>4 && a.IsCode(uint16(pc)):
+			vip++
+		}
+	}
+
+	if len(a.ComputedJumps) == 0 {
+		xochipScanned, schipScanned = 0, 0
+	}
+
+	d := Detection{Platform: PlatformChip8, Quirks: "default", Confidence: 0.5}
+	switch {
+	case len(rom) > 0x1000-0x200:
+		d = Detection{Platform: PlatformXOChip, Quirks: "xochip", Confidence: 0.9,
+			Reasons: []string{fmt.Sprintf("%d bytes do not fit in 4K of memory", len(rom))}}
+	case xochip > 0:
+		d = Detection{Platform: PlatformXOChip, Quirks: "xochip", Confidence: 0.95,
+			Reasons: []string{fmt.Sprintf("%d XO-CHIP instructions reached", xochip)}}
+	case schip > 0:
+		d = Detection{Platform: PlatformSchip, Quirks: "schip", Confidence: 0.9,
+			Reasons: []string{fmt.Sprintf("%d SCHIP instructions reached", schip)}}
+	case xochipScanned > 0:
+		d = Detection{Platform: PlatformXOChip, Quirks: "xochip", Confidence: scannedConfidence(xochipScanned),
+			Reasons: []string{fmt.Sprintf("%d XO-CHIP instructions found", xochipScanned)}}
+	case schipScanned > 0:
+		d = Detection{Platform: PlatformSchip, Quirks: "schip", Confidence: scannedConfidence(schipScanned),
+			Reasons: []string{fmt.Sprintf("%d SCHIP instructions found", schipScanned)}}
+	case vip > 0:
+		d = Detection{Platform: PlatformChip8, Quirks: "vip", Confidence: scannedConfidence(vip),
+			Reasons: []string{fmt.Sprintf("%d shifts of VY into another register", vip)}}
+	default:
+		d.Reasons = []string{"no platform specific instruction"}
+	}
+	if d.Platform == PlatformChip8 && len(rom) > maxVipRom {
+		d.Quirks = "default"
+		d.Reasons = append(d.Reasons, fmt.Sprintf("%d bytes are too large for the COSMAC VIP", len(rom)))
+	}
+	return d
+}
+
+// scannedConfidence grows with the number of clues, short of certainty
+func scannedConfidence(clues int) float64 {
+	confidence := 0.5 + 0.1*float64(clues)
+	if confidence > 0.8 {
+		confidence = 0.8
+	}
+	return confidence
+}
+
+// runDetect implements "chip8 detect ROM...", printing the platform of each ROM
+func runDetect(args []string) error {
+	flags := flag.NewFlagSet("detect", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: chip8 detect ROM...")
+	}
+	for _, path := range flags.Args() {
+		rom, err := ReadRom(path, "")
+		if err != nil {
+			return err
+		}
+		if info, ok := RomDB()[RomHash(rom)]; ok {
+			fmt.Printf("%s: %s, %s quirks, from the database (%s)\n", path, info.Platform, info.QuirksProfile(), info.Title)
+			continue
+		}
+		d := DetectPlatform(rom)
+		fmt.Printf("%s: %s, %s quirks, %.0f%% confident: %v\n", path, d.Platform, d.Quirks, d.Confidence*100, d.Reasons)
+	}
+	return nil
+}
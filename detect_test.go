@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestDetectPlatform(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		rom      []byte
+		platform string
+		quirks   string
+	}{
+		{"plain", []byte{0x00, 0xE0, 0x12, 0x00}, PlatformChip8, "default"},
+		{"hires reached", []byte{0x00, 0xFF, 0x12, 0x00}, PlatformSchip, "schip"},
+		{"big sprite", []byte{0xD0, 0x10, 0x12, 0x00}, PlatformSchip, "schip"},
+		{"long jump reached", []byte{0xF0, 0x00, 0x03, 0x00}, PlatformXOChip, "xochip"},
+		{"too large", make([]byte, 0x1000), PlatformXOChip, "xochip"},
+		{"shift of VY", []byte{0x81, 0x26, 0x12, 0x00}, PlatformChip8, "vip"},
+		{"shift of VX", []byte{0x81, 0x16, 0x12, 0x00}, PlatformChip8, "default"},
+		// F0 00 in a sprite is not an instruction
+		{"sprite", []byte{0xA2, 0x06, 0xD0, 0x12, 0x12, 0x04, 0xF0, 0x00}, PlatformChip8, "default"},
+		// unless computed jumps may reach it
+		{"computed jump", []byte{0xB2, 0x04, 0x00, 0x00, 0x00, 0xFF}, PlatformSchip, "schip"},
+	} {
+		d := DetectPlatform(test.rom)
+		if d.Platform != test.platform || d.Quirks != test.quirks || len(d.Reasons) == 0 {
+			t.Errorf("%s: got: %+v,but expected: %s with %s quirks", test.name, d, test.platform, test.quirks)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"testing"
+)
+
+func TestLetterbox(t *testing.T) {
+	tests := []struct {
+		w, h     int32
+		expected sdl.Rect
+	}{
+		{640, 320, sdl.Rect{X: 0, Y: 0, W: 640, H: 320}},
+		{800, 600, sdl.Rect{X: 16, Y: 108, W: 768, H: 384}}, // integer scale
+		{1000, 320, sdl.Rect{X: 180, Y: 0, W: 640, H: 320}}, // pillarbox
+		{40, 40, sdl.Rect{X: 0, Y: 10, W: 40, H: 20}},       // too small for an integer scale
+	}
+	for _, test := range tests {
+		if actual := letterbox(test.w, test.h, 64, 32); actual != test.expected {
+			t.Errorf("got: %v,but expected: %v in a %dx%d window", actual, test.expected, test.w, test.h)
+		}
+	}
+}
+
+func TestEmulator_NextOverlay(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	for _, expected := range []string{"grid", "scanlines", "none", "grid"} {
+		if actual := emu.nextOverlay(); actual != expected {
+			t.Errorf("got: %s,but expected: %s", actual, expected)
+		}
+	}
+	if err := checkOverlay("crt"); err == nil {
+		t.Errorf("got: no error,but expected: an unknown overlay error")
+	}
+}
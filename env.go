@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// Game reads the score and the end of a game out of the emulator
+type Game struct {
+	Name  string
+	Score func(e *Emulator) int
+	Over  func(e *Emulator) bool
+}
+
+// Action is a set of held keys, bit k standing for key k
+type Action uint16
+
+// Observation is the last frames of the display, oldest first
+type Observation [][2048]uint8
+
+// Env is a reinforcement learning environment over the emulator
+type Env struct {
+	FrameSkip  int  // frames run with the same action per Step
+	FrameStack int  // frames per observation
+	Speed      int  // instructions per frame, the ROM's when zero
+	Game       Game // reward and end of the game, none for unknown ROMs
+
+	rom    []byte
+	emu    *Emulator
+	frames Observation
+	score  int
+	done   bool
+}
+
+// NewEnv creates an environment for a ROM, scoring it with its built-in annotation if any
+func NewEnv(rom []byte) *Env {
+	env := &Env{
+		FrameSkip:  4,
+		FrameStack: 1,
+		rom:        rom,
+	}
+	if a := BuiltinAnnotations().Find(rom); a != nil {
+		env.Game = a.Game()
+	}
+	return env
+}
+
+// check returns an error for the settings which cannot run
+func (env *Env) check() error {
+	if env.FrameSkip < 1 || env.FrameStack < 1 {
+		return fmt.Errorf("frame skip %d and frame stack %d must be at least 1", env.FrameSkip, env.FrameStack)
+	}
+	return nil
+}
+
+// Reset starts a new episode and returns the first observation. On an
+// error the episode is left unstarted.
+func (env *Env) Reset(seed int64) (Observation, error) {
+	env.emu = nil
+	if err := env.check(); err != nil {
+		return nil, err
+	}
+	emu := NewEmulator(NewFonts())
+	emu.Seed(seed)
+	if err := emu.Load(env.rom); err != nil {
+		return nil, err
+	}
+	env.emu = emu
+	env.done = false
+	if env.Speed > 0 {
+		env.emu.Speed = env.Speed
+	}
+	env.score = env.currentScore()
+	env.frames = env.frames[:0]
+	for i := 0; i < env.FrameStack; i++ {
+		env.frames = append(env.frames, env.emu.Gfx)
+	}
+	return env.observation(), nil
+}
+
+// Step holds the keys of action for FrameSkip frames and returns the
+// observation, the change of the score and whether the episode is over.
+// An error of the program, such as a stack overflow, ends the episode and
+// is returned.
+func (env *Env) Step(action Action) (Observation, float64, bool, error) {
+	if env.emu == nil {
+		return nil, 0, true, errors.New("no episode started, reset first")
+	}
+	var keys [16]bool
+	for k := range keys {
+		keys[k] = action&(1<<uint(k)) != 0
+	}
+	env.emu.SetKeys(keys)
+
+	var err error
+	for i := 0; i < env.FrameSkip && !env.done; i++ {
+		if err = env.emu.Frame(); err != nil {
+			env.done = true
+		}
+		if env.Game.Over != nil && env.Game.Over(env.emu) {
+			env.done = true
+		}
+	}
+	env.frames = append(env.frames[1:], env.emu.Gfx)
+
+	score := env.currentScore()
+	reward := float64(score - env.score)
+	env.score = score
+	return env.observation(), reward, env.done, err
+}
+
+// Emulator returns the emulator of the current episode
+func (env *Env) Emulator() *Emulator {
+	return env.emu
+}
+
+func (env *Env) currentScore() int {
+	if env.Game.Score == nil {
+		return 0
+	}
+	return env.Game.Score(env.emu)
+}
+
+func (env *Env) observation() Observation {
+	return append(Observation(nil), env.frames...)
+}
+
+// envRequest is a line sent to the environment server:
+//
+//	{"cmd": "reset", "seed": 1}
+//	{"cmd": "step", "action": 64}
+type envRequest struct {
+	Cmd    string `json:"cmd"`
+	Seed   int64  `json:"seed"`
+	Action Action `json:"action"`
+}
+
+// envResponse is the line answering a request. Obs is the observation as
+// FrameStack*32*64 bytes, one byte per pixel, encoded in base64.
+type envResponse struct {
+	Obs    []byte  `json:"obs,omitempty"`
+	Reward float64 `json:"reward"`
+	Done   bool    `json:"done"`
+	Error  string  `json:"error,omitempty"`
+}
+
+func (obs Observation) bytes() []byte {
+	var b []byte
+	for _, frame := range obs {
+		b = append(b, frame[:]...)
+	}
+	return b
+}
+
+// serveEnv answers the requests of a connection on its own environment.
+// A panic closes the connection only, not the server.
+func serveEnv(conn net.Conn, newEnv func() *Env) {
+	defer conn.Close()
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("connection %s: %v\n", conn.RemoteAddr(), p)
+		}
+	}()
+	env := newEnv()
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req envRequest
+		var resp envResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			switch req.Cmd {
+			case "reset":
+				obs, err := env.Reset(req.Seed)
+				if err != nil {
+					resp.Error, resp.Done = err.Error(), true
+				}
+				resp.Obs = obs.bytes()
+			case "step":
+				obs, reward, done, err := env.Step(req.Action)
+				if err != nil {
+					resp.Error = err.Error()
+				}
+				resp.Obs, resp.Reward, resp.Done = obs.bytes(), reward, done
+			default:
+				resp.Error = fmt.Sprintf("unknown command %q", req.Cmd)
+			}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// runEnv implements "chip8 env [flags] ROM", serving environments over a local socket
+func runEnv(args []string) error {
+	flags := flag.NewFlagSet("env", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:5555", "`address` to listen on, unix:PATH for a unix socket")
+	frameSkip := flags.Int("frame-skip", 4, "frames run with the same action per step")
+	frameStack := flags.Int("frame-stack", 1, "frames per observation")
+	speed := flags.Int("speed", 0, "instructions per frame, the ROM's when zero")
+	annotations := flags.String("annotations", "", "score the ROM with the annotation files of `dir`")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: chip8 env [flags] ROM")
+	}
+	if *frameSkip < 1 || *frameStack < 1 {
+		return fmt.Errorf("-frame-skip and -frame-stack must be at least 1")
+	}
+	rom, err := ReadRom(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+	game := NewEnv(rom).Game
+	if *annotations != "" {
+		as, err := LoadAnnotations(*annotations)
+		if err != nil {
+			return err
+		}
+		if a := as.Find(rom); a != nil {
+			game = a.Game()
+		}
+	}
+
+	network, address := "tcp", *addr
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Printf("serving %s on %s\n", flags.Arg(0), *addr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go serveEnv(conn, func() *Env {
+			env := NewEnv(rom)
+			env.FrameSkip, env.FrameStack, env.Speed = *frameSkip, *frameStack, *speed
+			env.Game = game
+			return env
+		})
+	}
+}
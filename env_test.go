@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestEnv_Brix(t *testing.T) {
+	rom, err := os.ReadFile("roms/BRIX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnv(rom)
+	env.FrameStack = 4
+	env.Speed = 10
+	if env.Game.Name != "BRIX" {
+		t.Fatalf("got: %q,but expected: BRIX", env.Game.Name)
+	}
+	obs, err := env.Reset(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs) != 4 {
+		t.Errorf("got: %v frames,but expected: 4", len(obs))
+	}
+	total := 0.0
+	done := false
+	for i := 0; i < 20000 && !done; i++ {
+		// follow the ball with the paddle
+		action := Action(1 << 4)
+		if env.Emulator().V[6] > env.Emulator().V[0xC]+2 {
+			action = 1 << 6
+		}
+		var reward float64
+		obs, reward, done, err = env.Step(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += reward
+	}
+	if !done {
+		t.Error("got: not done,but expected the game to be over")
+	}
+	if total == 0 || total != float64(env.Emulator().V[5]) {
+		t.Errorf("got: total reward %v,but expected: %v bricks", total, env.Emulator().V[5])
+	}
+}
+
+func TestServeEnv(t *testing.T) {
+	rom, err := os.ReadFile("roms/PONG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := net.Pipe()
+	go serveEnv(server, func() *Env { return NewEnv(rom) })
+	defer client.Close()
+
+	r := bufio.NewScanner(client)
+	for _, req := range []string{`{"cmd": "reset", "seed": 1}`, `{"cmd": "step", "action": 2}`} {
+		client.Write([]byte(req + "\n"))
+		if !r.Scan() {
+			t.Fatal(r.Err())
+		}
+		var resp envResponse
+		if err := json.Unmarshal(r.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "" || len(resp.Obs) != 2048 {
+			t.Errorf("got: error %q and %v bytes,but expected: 2048 bytes", resp.Error, len(resp.Obs))
+		}
+	}
+}
+
+func TestEnv_Errors(t *testing.T) {
+	env := NewEnv([]byte{0x12, 0x00})
+	env.FrameStack = 0
+	if _, err := env.Reset(1); err == nil {
+		t.Errorf("got: no error,but expected: an error for a frame stack of 0")
+	}
+	if _, _, done, err := env.Step(0); err == nil || !done {
+		t.Errorf("got: %v %v,but expected: an error for a frame stack of 0", done, err)
+	}
+
+	// a ROM too large for the memory leaves the episode unstarted
+	env = NewEnv(make([]byte, 4000))
+	if _, err := env.Reset(1); err == nil {
+		t.Errorf("got: no error,but expected: an error for a ROM of 4000 bytes")
+	}
+	if _, _, done, err := env.Step(0); err == nil || !done {
+		t.Errorf("got: %v %v,but expected: an error asking to reset first", done, err)
+	}
+
+	// a subroutine calling itself overflows the stack
+	env = NewEnv([]byte{0x22, 0x00})
+	if _, err := env.Reset(1); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	done := false
+	for i := 0; i < 100 && !done; i++ {
+		_, _, done, err = env.Step(0)
+	}
+	if _, ok := err.(*StackError); !ok || !done {
+		t.Errorf("got: %v,but expected: a stack overflow ending the episode", err)
+	}
+}
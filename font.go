@@ -0,0 +1,105 @@
+package main
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"strings"
+)
+
+// glyphs is a 3x5 bitmap font for the messages drawn over the display, a
+// row per byte with the leftmost pixel in bit 2
+var glyphs = map[rune][5]uint8{
+	'A':  {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B':  {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C':  {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D':  {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E':  {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F':  {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G':  {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H':  {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I':  {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J':  {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K':  {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L':  {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M':  {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N':  {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O':  {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P':  {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q':  {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R':  {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S':  {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T':  {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U':  {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V':  {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W':  {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X':  {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y':  {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z':  {0b111, 0b001, 0b010, 0b100, 0b111},
+	'0':  {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1':  {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2':  {0b110, 0b001, 0b010, 0b100, 0b111},
+	'3':  {0b110, 0b001, 0b010, 0b001, 0b110},
+	'4':  {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5':  {0b111, 0b100, 0b110, 0b001, 0b110},
+	'6':  {0b011, 0b100, 0b111, 0b101, 0b111},
+	'7':  {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8':  {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9':  {0b111, 0b101, 0b111, 0b001, 0b110},
+	' ':  {},
+	'.':  {0, 0, 0, 0, 0b010},
+	',':  {0, 0, 0, 0b010, 0b100},
+	':':  {0, 0b010, 0, 0b010, 0},
+	'!':  {0b010, 0b010, 0b010, 0, 0b010},
+	'?':  {0b110, 0b001, 0b010, 0, 0b010},
+	'-':  {0, 0, 0b111, 0, 0},
+	'+':  {0, 0b010, 0b111, 0b010, 0},
+	'=':  {0, 0b111, 0, 0b111, 0},
+	'/':  {0b001, 0b001, 0b010, 0b100, 0b100},
+	'\'': {0b010, 0b010, 0, 0, 0},
+	'(':  {0b001, 0b010, 0b010, 0b010, 0b001},
+	')':  {0b100, 0b010, 0b010, 0b010, 0b100},
+	'[':  {0b011, 0b010, 0b010, 0b010, 0b011},
+	']':  {0b110, 0b010, 0b010, 0b010, 0b110},
+	'>':  {0b100, 0b010, 0b001, 0b010, 0b100},
+	'<':  {0b001, 0b010, 0b100, 0b010, 0b001},
+	'#':  {0b101, 0b111, 0b101, 0b111, 0b101},
+	'%':  {0b101, 0b001, 0b010, 0b100, 0b101},
+	'_':  {0, 0, 0, 0, 0b111},
+}
+
+// Size of a glyph in font pixels, spacing included
+const (
+	glyphWidth  = 4
+	glyphHeight = 6
+)
+
+// TextWidth returns the width of a text drawn at the given scale
+func TextWidth(text string, scale int32) int32 {
+	return int32(len([]rune(text))) * glyphWidth * scale
+}
+
+// drawText draws a text with its top left corner at x, y, each font pixel
+// being a square of scale pixels. Letters are drawn in upper case and the
+// characters missing from the font as '?'.
+func drawText(renderer *sdl.Renderer, x, y, scale int32, text string, color Color) {
+	for _, r := range strings.ToUpper(text) {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		for row, bits := range glyph {
+			for col := int32(0); col < 3; col++ {
+				if bits&(0b100>>uint(col)) != 0 {
+					fillRect(renderer, sdl.Rect{X: x + col*scale, Y: y + int32(row)*scale, W: scale, H: scale}, color, 255)
+				}
+			}
+		}
+		x += glyphWidth * scale
+	}
+}
+
+// fillRect fills a rectangle of the current render target with a color
+// and an opacity
+func fillRect(renderer *sdl.Renderer, rect sdl.Rect, color Color, alpha uint8) {
+	renderer.SetDrawColor(color.R, color.G, color.B, alpha)
+	renderer.FillRect(&rect)
+}
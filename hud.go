@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/veandco/go-sdl2/sdl"
+	"strings"
+)
+
+// hudColumns is the width of the lines of the HUD, in characters
+const hudColumns = 16
+
+// HUD is a side panel showing the speed and the state of the emulator next
+// to the display
+type HUD struct {
+	FPS     int // frames run during the last second
+	IPS     int // instructions executed during the last second
+	started bool
+	since   uint32 // ticks at the start of the measure
+	frames  int    // frames run since
+	cycle   uint64 // instructions executed before
+}
+
+// Measure counts the frames run, computing the rates every second from
+// the ticks in milliseconds and the instructions executed so far
+func (h *HUD) Measure(ticks uint32, frames int, cycle uint64) {
+	if !h.started {
+		h.started, h.since, h.cycle = true, ticks, cycle
+	}
+	h.frames += frames
+	if elapsed := ticks - h.since; elapsed >= 1000 {
+		h.FPS = h.frames * 1000 / int(elapsed)
+		h.IPS = int((cycle - h.cycle) * 1000 / uint64(elapsed))
+		h.since, h.frames, h.cycle = ticks, 0, cycle
+	}
+}
+
+// Lines returns the text of the HUD for the state of an emulator
+func (h *HUD) Lines(e *Emulator) []string {
+	var opcode uint16
+	if int(e.Pc)+1 < len(e.Memory) {
+		opcode = uint16(e.Memory[e.Pc])<<8 | uint16(e.Memory[e.Pc+1])
+	}
+	lines := []string{
+		fmt.Sprintf("FPS %d", h.FPS),
+		fmt.Sprintf("IPS %d", h.IPS),
+		"",
+		fmt.Sprintf("PC %03X  %04X", e.Pc, opcode),
+		Disassemble(opcode),
+		fmt.Sprintf("I  %03X", e.I),
+		fmt.Sprintf("DT %02X  ST %02X", e.delayTimer, e.soundTimer),
+		"",
+	}
+	for x := 0; x < 16; x += 2 {
+		lines = append(lines, fmt.Sprintf("V%X %02X  V%X %02X", x, e.V[x], x+1, e.V[x+1]))
+	}
+	var keys []string
+	for k, pressed := range e.keys {
+		if pressed {
+			keys = append(keys, fmt.Sprintf("%X", k))
+		}
+	}
+	return append(lines, "", "KEYS "+strings.Join(keys, " "))
+}
+
+// hudScale returns the size of the font of the HUD for a window height
+func hudScale(height int32, lines int) int32 {
+	scale := height / (int32(lines)*(glyphHeight+1) + 4)
+	if scale < 1 {
+		return 1
+	}
+	if scale > 3 {
+		return 3
+	}
+	return scale
+}
+
+// hudWidth returns the width of the HUD panel at a font scale
+func hudWidth(scale int32) int32 {
+	return (hudColumns*glyphWidth + 4) * scale
+}
+
+// drawHUD draws the HUD in a panel of the window
+func (e *Emulator) drawHUD(panel sdl.Rect, lines []string, scale int32) {
+	fillRect(e.renderer, panel, Color{20, 20, 20}, 255)
+	y := panel.Y + 2*scale
+	for _, line := range lines {
+		if len(line) > hudColumns {
+			line = line[:hudColumns]
+		}
+		drawText(e.renderer, panel.X+2*scale, y, scale, line, Color{200, 200, 200})
+		y += (glyphHeight + 1) * scale
+	}
+}
+
+// toggleHUD shows or hides the HUD
+func (e *Emulator) toggleHUD() {
+	if e.hud == nil {
+		e.hud = &HUD{}
+	} else {
+		e.hud = nil
+	}
+	e.shouldDraw = true
+}
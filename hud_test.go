@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestHUD_Measure(t *testing.T) {
+	h := &HUD{}
+	h.Measure(500, 1, 100)
+	for ticks := uint32(500); ticks < 1500; ticks += 100 {
+		h.Measure(ticks+100, 6, 100+uint64(ticks+100-500)*6)
+	}
+	if h.FPS != 61 || h.IPS != 6000 {
+		t.Errorf("got: %d FPS %d IPS,but expected: 61 FPS 6000 IPS", h.FPS, h.IPS)
+	}
+}
+
+func TestHUD_Lines(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	if err := emu.Load([]byte{0xD0, 0x1F}); err != nil {
+		t.Fatal(err)
+	}
+	emu.V[0xB], emu.I, emu.soundTimer = 0x2A, 0x2F0, 3
+	emu.keys[0xA], emu.keys[1] = true, true
+	lines := (&HUD{}).Lines(emu)
+	expected := map[int]string{
+		3:  "PC 200  D01F",
+		4:  "DRW V0, V1, 15",
+		5:  "I  2F0",
+		6:  "DT 00  ST 03",
+		13: "VA 00  VB 2A",
+		17: "KEYS 1 A",
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("got: %q,but expected: %q", lines[i], line)
+		}
+	}
+	for _, line := range lines {
+		if len(line) > hudColumns {
+			t.Errorf("got: %q,but expected: at most %d characters", line, hudColumns)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/veandco/go-sdl2/sdl"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// errEscape is returned by Run when Escape is pressed in a game started
+// from the launcher
+var errEscape = errors.New("back to the launcher")
+
+// maxRecentRoms is the number of recently played ROMs remembered
+const maxRecentRoms = 10
+
+// RecentRoms are the paths of the recently played ROMs, the last one first
+type RecentRoms struct {
+	path  string
+	Paths []string
+}
+
+// LoadRecentRoms reads the list of recently played ROMs, empty when the file
+// does not exist yet. An empty path keeps the list in memory only.
+func LoadRecentRoms(path string) (*RecentRoms, error) {
+	r := &RecentRoms{path: path}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.Paths); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Add moves a ROM at the top of the list and saves it
+func (r *RecentRoms) Add(path string) error {
+	paths := []string{path}
+	for _, p := range r.Paths {
+		if p != path && len(paths) < maxRecentRoms {
+			paths = append(paths, p)
+		}
+	}
+	r.Paths = paths
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Paths, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0644)
+}
+
+// Contains reports whether a ROM was played recently
+func (r *RecentRoms) Contains(path string) bool {
+	for _, p := range r.Paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// launcherEntry is a ROM of the launcher
+type launcherEntry struct {
+	path       string
+	rom        []byte
+	info       *RomInfo
+	screenshot *[2048]uint8 // the display when last left, or after a few seconds of play
+}
+
+// name returns the title of the ROM, or its file name when unknown
+func (entry *launcherEntry) name() string {
+	if entry.info.Title != "" {
+		return entry.info.Title
+	}
+	return filepath.Base(entry.path)
+}
+
+// thumbnail returns the screenshot of the ROM, running it for a few
+// seconds without input the first time
+func (entry *launcherEntry) thumbnail() *[2048]uint8 {
+	if entry.screenshot == nil {
+		emu := NewEmulator(NewFonts())
+		emu.Seed(0)
+		if emu.Load(entry.rom) == nil {
+			for i := 0; i < 3*60 && emu.Frame() == nil; i++ {
+			}
+		}
+		entry.screenshot = &emu.Gfx
+	}
+	return entry.screenshot
+}
+
+// Launcher lists the ROMs of a directory, the recently played ones first
+type Launcher struct {
+	Dir      string
+	entries  []*launcherEntry
+	recent   *RecentRoms
+	selected int
+	top      int // first entry shown
+}
+
+// NewLauncher reads the ROMs of a directory
+func NewLauncher(dir string, recent *RecentRoms) (*Launcher, error) {
+	paths, err := RomFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Launcher{Dir: dir, recent: recent}
+	for _, path := range paths {
+		rom, err := ReadRom(path, "")
+		if err != nil {
+			log.Printf("%s: %v\n", path, err)
+			continue
+		}
+		l.entries = append(l.entries, &launcherEntry{path: path, rom: rom, info: IdentifyRom(rom)})
+	}
+	if len(l.entries) == 0 {
+		return nil, fmt.Errorf("no ROM in %s", dir)
+	}
+	l.sort()
+	return l, nil
+}
+
+// sort moves the recently played ROMs first, the last one at the top
+func (l *Launcher) sort() {
+	var entries []*launcherEntry
+	for _, path := range l.recent.Paths {
+		for _, entry := range l.entries {
+			if entry.path == path {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	for _, entry := range l.entries {
+		if !l.recent.Contains(entry.path) {
+			entries = append(entries, entry)
+		}
+	}
+	l.entries = entries
+	l.selected, l.top = 0, 0
+}
+
+// Layout of the launcher, in pixels of a 640x320 canvas scaled into the window
+const (
+	launcherWidth     = 640
+	launcherHeight    = 320
+	launcherScale     = 2
+	launcherRow       = (glyphHeight + 1) * launcherScale
+	launcherListTop   = 30
+	launcherRows      = (launcherHeight - launcherListTop) / launcherRow
+	launcherPanelX    = 340
+	launcherThumbnail = 4 // pixels per CHIP-8 pixel of the thumbnail
+)
+
+// move moves the selection by delta entries, scrolling the list to keep it visible
+func (l *Launcher) move(delta int) {
+	l.selected += delta
+	if l.selected < 0 {
+		l.selected = 0
+	}
+	if l.selected >= len(l.entries) {
+		l.selected = len(l.entries) - 1
+	}
+	if l.selected < l.top {
+		l.top = l.selected
+	}
+	if l.selected >= l.top+launcherRows {
+		l.top = l.selected - launcherRows + 1
+	}
+}
+
+// Choose shows the launcher in the window of e until a ROM is chosen with
+// Return. It returns false when the window is closed or Escape is pressed.
+func (l *Launcher) Choose(e *Emulator) (*launcherEntry, bool) {
+	e.window.SetTitle("CHIP-8")
+	canvas, err := e.renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_TARGET, launcherWidth, launcherHeight)
+	if err != nil {
+		log.Println(err)
+		return nil, false
+	}
+	defer canvas.Destroy()
+	for {
+		e.renderer.SetRenderTarget(canvas)
+		l.draw(e.renderer)
+		present(e.renderer, canvas, launcherWidth, launcherHeight, windowArea(e.renderer))
+		e.renderer.Present()
+		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
+			switch et := ev.(type) {
+			case *sdl.QuitEvent:
+				return nil, false
+			case *sdl.KeyboardEvent:
+				if et.Type != sdl.KEYDOWN {
+					continue
+				}
+				switch et.Keysym.Scancode {
+				case sdl.SCANCODE_UP:
+					l.move(-1)
+				case sdl.SCANCODE_DOWN:
+					l.move(1)
+				case sdl.SCANCODE_PAGEUP:
+					l.move(-launcherRows)
+				case sdl.SCANCODE_PAGEDOWN:
+					l.move(launcherRows)
+				case sdl.SCANCODE_HOME:
+					l.move(-len(l.entries))
+				case sdl.SCANCODE_END:
+					l.move(len(l.entries))
+				case sdl.SCANCODE_F11:
+					e.toggleFullscreen()
+				case sdl.SCANCODE_RETURN:
+					return l.entries[l.selected], true
+				case sdl.SCANCODE_ESCAPE:
+					return nil, false
+				}
+			}
+		}
+		sdl.Delay(1000 / 60)
+	}
+}
+
+// fit cuts a text to the number of characters fitting in width pixels
+func fit(text string, width int32) string {
+	n := int(width / (glyphWidth * launcherScale))
+	if r := []rune(text); len(r) > n {
+		return string(r[:n])
+	}
+	return text
+}
+
+func (l *Launcher) draw(renderer *sdl.Renderer) {
+	text := Color{200, 200, 200}
+	dim := Color{120, 120, 120}
+	highlight := Color{60, 60, 90}
+	fillRect(renderer, sdl.Rect{X: 0, Y: 0, W: launcherWidth, H: launcherHeight}, Color{20, 20, 20}, 255)
+	drawText(renderer, 10, 8, launcherScale, fit(l.Dir, launcherPanelX-20), dim)
+
+	for row := 0; row < launcherRows && l.top+row < len(l.entries); row++ {
+		entry := l.entries[l.top+row]
+		y := int32(launcherListTop + row*launcherRow)
+		if l.top+row == l.selected {
+			fillRect(renderer, sdl.Rect{X: 4, Y: y - 2, W: launcherPanelX - 14, H: launcherRow}, highlight, 255)
+		}
+		name := "  " + entry.name()
+		if l.recent.Contains(entry.path) {
+			name = "* " + entry.name()
+		}
+		drawText(renderer, 10, y, launcherScale, fit(name, launcherPanelX-20), text)
+	}
+
+	entry := l.entries[l.selected]
+	palette := DefaultPalette
+	if len(entry.info.Palette) > 0 {
+		palette = entry.info.Palette
+	}
+	for i, pixel := range entry.thumbnail() {
+		rect := sdl.Rect{
+			X: launcherPanelX + int32(i%64)*launcherThumbnail,
+			Y: launcherListTop + int32(i/64)*launcherThumbnail,
+			W: launcherThumbnail,
+			H: launcherThumbnail,
+		}
+		fillRect(renderer, rect, palette.Color(pixel), 255)
+	}
+
+	var lines []string
+	lines = append(lines, entry.name())
+	if entry.info.Author != "" {
+		lines = append(lines, entry.info.Author)
+	}
+	if entry.info.Year != 0 {
+		lines = append(lines, fmt.Sprint(entry.info.Year))
+	}
+	lines = append(lines, fmt.Sprintf("%s, %s quirks", entry.info.Platform, entry.info.QuirksProfile()))
+	if !entry.info.Known {
+		lines = append(lines, "not in the database")
+	}
+	lines = append(lines, "", "Return: play", "Escape: back")
+	y := int32(launcherListTop + 32*launcherThumbnail + 12)
+	for _, line := range lines {
+		drawText(renderer, launcherPanelX, y, launcherScale, fit(line, launcherWidth-launcherPanelX), text)
+		y += launcherRow
+	}
+}
+
+// runLauncher shows the ROMs of dir and plays the chosen ones in the same
+// window until it is closed. setDisplay sets the window options and
+// setGame the options of the games, once their ROM is loaded.
+func runLauncher(dir string, progressPath string, setDisplay func(*Emulator), setGame func(*Emulator)) error {
+	recentPath, err := configPath("recent.json")
+	if err != nil {
+		log.Println(err)
+	}
+	recent, err := LoadRecentRoms(recentPath)
+	if err != nil {
+		return err
+	}
+	launcher, err := NewLauncher(dir, recent)
+	if err != nil {
+		return err
+	}
+	display := NewEmulator(NewFonts())
+	setDisplay(display)
+	if err := display.InitDisplay(); err != nil {
+		return err
+	}
+	defer display.DestroyDisplay()
+
+	for {
+		entry, ok := launcher.Choose(display)
+		if !ok {
+			return nil
+		}
+		if err := recent.Add(entry.path); err != nil {
+			log.Println(err)
+		}
+		emu := NewEmulator(NewFonts())
+		if err := emu.Load(entry.rom); err != nil {
+			log.Printf("%s: %v\n", entry.path, err)
+			continue
+		}
+		setGame(emu)
+		if err := trackAchievements(emu, entry.rom, progressPath); err != nil {
+			log.Println(err)
+		}
+		emu.useDisplay(display)
+		emu.escapable = true
+		if emu.Debug {
+			emu.toggleDebugger()
+		}
+		err := emu.Run()
+		emu.closeDebugger()
+		if err := emu.StopRecording(); err != nil {
+			log.Println(err)
+		}
+		screenshot := emu.Gfx
+		entry.screenshot = &screenshot
+		launcher.sort()
+		switch {
+		case err == nil:
+			return nil
+		case err != errEscape:
+			log.Printf("%s: %v\n", entry.path, err)
+		}
+	}
+}
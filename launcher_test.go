@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLauncher_Recent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recent.json")
+	recent, err := LoadRecentRoms(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	launcher, err := NewLauncher("roms", recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := launcher.entries[0].path; actual != filepath.Join("roms", "15PUZZLE") {
+		t.Errorf("got: %v,but expected: roms/15PUZZLE first", actual)
+	}
+	recent.Add(filepath.Join("roms", "UFO"))
+	recent.Add(filepath.Join("roms", "BRIX"))
+	recent.Add(filepath.Join("roms", "UFO"))
+	launcher.sort()
+	if launcher.entries[0].name() != "UFO" || launcher.entries[1].name() != "Brix" || launcher.entries[2].name() != "15 Puzzle" {
+		t.Errorf("got: %v %v %v,but expected: UFO Brix 15 Puzzle", launcher.entries[0].name(), launcher.entries[1].name(), launcher.entries[2].name())
+	}
+
+	recent, err = LoadRecentRoms(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent.Paths) != 2 || recent.Paths[0] != filepath.Join("roms", "UFO") {
+		t.Errorf("got: %v,but expected: [roms/UFO roms/BRIX]", recent.Paths)
+	}
+
+	launcher.move(100)
+	if launcher.selected != len(launcher.entries)-1 || launcher.top != len(launcher.entries)-launcherRows {
+		t.Errorf("got: selected %d top %d,but expected: the last entry visible", launcher.selected, launcher.top)
+	}
+	if thumbnail := launcher.entries[0].thumbnail(); *thumbnail == [2048]uint8{} {
+		t.Errorf("got: an empty thumbnail,but expected: the display of UFO")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxRom is the size of the memory available to a ROM
+const maxRom = 4096 - 0x200
+
+// ReadRom reads a ROM file: the raw program bytes, a gzip file or a zip
+// archive, of which entry is read. The entry may be empty for an archive of
+// a single file, otherwise the error lists the entries. A file only starting
+// like a gzip file is read as raw bytes.
+func ReadRom(path string, entry string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZipRom(data, entry)
+	case bytes.HasPrefix(data, []byte{0x1F, 0x8B, 0x08}):
+		if r, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			return readAllRom(r)
+		}
+	}
+	if entry != "" {
+		return nil, fmt.Errorf("%s is not an archive", path)
+	}
+	return data, nil
+}
+
+// readAllRom reads a ROM, failing early when it does not fit in memory
+func readAllRom(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRom+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRom {
+		return nil, fmt.Errorf("ROM larger than %d bytes does not fit in memory", maxRom)
+	}
+	return data, nil
+}
+
+// ZipEntries lists the files of a zip archive
+func ZipEntries(path string) ([]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return zipFiles(&r.Reader), nil
+}
+
+func zipFiles(r *zip.Reader) []string {
+	var names []string
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readZipRom(data []byte, entry string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	names := zipFiles(r)
+	if entry == "" {
+		if len(names) != 1 {
+			return nil, fmt.Errorf("choose an entry of the archive: %s", strings.Join(names, ", "))
+		}
+		entry = names[0]
+	}
+	f, err := r.Open(entry)
+	if err != nil {
+		return nil, fmt.Errorf("no entry %q in the archive: %s", entry, strings.Join(names, ", "))
+	}
+	defer f.Close()
+	return readAllRom(f)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, files map[string][]byte) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	w.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRom(t *testing.T) {
+	dir := t.TempDir()
+	rom, err := os.ReadFile("roms/BRIX")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single := filepath.Join(dir, "brix.zip")
+	writeZip(t, single, map[string][]byte{"BRIX": rom})
+	if actual, err := ReadRom(single, ""); err != nil || !bytes.Equal(actual, rom) {
+		t.Errorf("got: %v,but expected: BRIX from the zip", err)
+	}
+
+	several := filepath.Join(dir, "games.zip")
+	writeZip(t, several, map[string][]byte{"BRIX": rom, "PONG": {0x12, 0x00}})
+	if _, err := ReadRom(several, ""); err == nil || !strings.Contains(err.Error(), "BRIX, PONG") {
+		t.Errorf("got: %v,but expected: an error listing BRIX, PONG", err)
+	}
+	if actual, err := ReadRom(several, "PONG"); err != nil || !bytes.Equal(actual, []byte{0x12, 0x00}) {
+		t.Errorf("got: %v %v,but expected: PONG from the zip", actual, err)
+	}
+	if names, err := ZipEntries(several); err != nil || len(names) != 2 {
+		t.Errorf("got: %v %v,but expected: [BRIX PONG]", names, err)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(rom)
+	w.Close()
+	gz := filepath.Join(dir, "BRIX.gz")
+	os.WriteFile(gz, buf.Bytes(), 0644)
+	if actual, err := ReadRom(gz, ""); err != nil || !bytes.Equal(actual, rom) {
+		t.Errorf("got: %v,but expected: BRIX from the gzip file", err)
+	}
+
+	big := filepath.Join(dir, "big.zip")
+	writeZip(t, big, map[string][]byte{"BIG": make([]byte, maxRom+1)})
+	if _, err := ReadRom(big, ""); err == nil {
+		t.Errorf("got: no error,but expected: a ROM too large")
+	}
+
+	// a program starting like a gzip file
+	raw := filepath.Join(dir, "raw.ch8")
+	os.WriteFile(raw, []byte{0x1F, 0x8B, 0x08, 0x00, 0x12, 0x00}, 0644)
+	if actual, err := ReadRom(raw, ""); err != nil || len(actual) != 6 {
+		t.Errorf("got: %v %v,but expected: the 6 raw bytes", actual, err)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
-	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 // NewFonts creates fonts array
@@ -74,13 +78,57 @@ type Emulator struct {
 	Gfx        [2048]uint8 // 2048 black or white pixels
 	delayTimer uint8       // Timer registor for general purpose
 	soundTimer uint8       // Timer registor for sound
+	beeping    bool        // the sound timer was running during the last frame
 	Stack      [16]uint16  // to store current pc
 	Sp         uint16      // stack pointer
 	keys       [16]bool    // to store current stats of key
 	keyMap     map[int]byte
 	shouldDraw bool
-	surface    *sdl.Surface
 	window     *sdl.Window
+	renderer   *sdl.Renderer
+	screen     *sdl.Texture // the 64x32 display, scaled into the window
+	Scale      int32        // initial size of the window, in pixels per CHIP-8 pixel
+	Fullscreen bool
+	Overlay    string // drawn over the display: OverlayNone, OverlayGrid or OverlayScanlines
+	// CaptureScale is the size of the screenshots and recordings, in pixels
+	// per CHIP-8 pixel
+	CaptureScale int
+	Debug        bool       // open the debugger with the display
+	recorder     *Recorder  // records the frames, nil when not recording
+	Cycle        uint64     // number of instructions executed so far
+	tracers      []Tracer   // notified after every instruction, see AddTracer
+	writes       []MemWrite // memory writes of the current instruction while tracing
+	executed     [4096]bool // bytes fetched as part of an instruction
+	written      [4096]bool // bytes written by the program
+	fresh        [4096]bool // bytes written and not executed since
+	events       []MemoryEvent
+	// OnMemoryEvent, when set, is called when code is overwritten or
+	// when freshly written memory is executed
+	OnMemoryEvent func(ev MemoryEvent)
+	rand          *rand.Rand
+	err           error        // set by an instruction which could not be executed
+	Speed         int          // instructions per frame
+	cache         *decodeCache // decoded instructions, nil for the interpreter
+	frozen        []Cheat      // applied at the end of every frame, see Freeze
+	pokes         []Cheat      // applied again by the resets, see Poke
+	Quirks        Quirks       // set by Load from the ROM database
+	Palette       Palette      // set by Load from the ROM database
+	Rom           *RomInfo     // the loaded ROM, nil before Load
+	Persistence   *Persistence // display filter, nil to draw Gfx as is
+	// OnFrame, when set, is called at the end of every frame
+	OnFrame     func()
+	toast       string // message shown over the display, see Toast
+	toastFrames int    // frames left to show the toast
+	escapable   bool   // Run returns errEscape when Escape is pressed
+	fonts       [80]uint8
+	rom         []byte // the loaded ROM, patched, see Reset
+	romStart    uint16 // where the ROM was loaded
+	paused      bool
+	advancing   bool      // run a frame while paused
+	turbo       bool      // fast-forward while Tab is held
+	slow        bool      // slow motion
+	hud         *HUD      // shown beside the display, nil when hidden
+	debugger    *Debugger // its window is open when not nil
 }
 
 // NewEmulator creates Emulator
@@ -91,57 +139,255 @@ func NewEmulator(fonts [80]uint8) *Emulator {
 	}
 	keyMap := NewKeyMap()
 	return &Emulator{
-		Pc:     0x200,
-		Opcode: 0,
-		Memory: memory,
-		I:      0,
-		Sp:     0,
-		keyMap: keyMap,
+		Pc:      0x200,
+		Opcode:  0,
+		Memory:  memory,
+		I:       0,
+		Sp:      0,
+		keyMap:  keyMap,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		Speed:   1,
+		Palette: DefaultPalette,
+		Scale:   10,
+		// 256x128 captures
+		CaptureScale: 4,
+		fonts:        fonts,
 	}
 }
 
-func (e *Emulator) InitDisplay() {
+// Seed seeds the random numbers of CXNN, to replay a run
+func (e *Emulator) Seed(seed int64) {
+	e.rand.Seed(seed)
+}
+
+// InitDisplay opens the window, returning an error when SDL cannot
+func (e *Emulator) InitDisplay() error {
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		return
+		return fmt.Errorf("failed to initialize SDL: %v", err)
 	}
 	//defer sdl.Quit()
 
-	window, err := sdl.CreateWindow("CHIP-8", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 640, 320, sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow(e.title(), sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		64*e.Scale, 32*e.Scale, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
-		fmt.Fprint(os.Stderr, "Failed to create renderer: %s\n", err)
-		os.Exit(2)
+		sdl.Quit()
+		return fmt.Errorf("failed to create window: %v", err)
 	}
 	//defer window.Destroy()
 
 	window.Raise()
 	e.window = window
+	if e.Fullscreen {
+		window.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
+	}
 
-	// window has been created, now need to get the window surface to draw on window
-	surface, err := window.GetSurface()
+	// the display is drawn into a 64x32 texture, then scaled into the window
+	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_TARGETTEXTURE)
+	if err != nil {
+		window.Destroy()
+		sdl.Quit()
+		return fmt.Errorf("failed to create renderer: %v", err)
+	}
+	e.renderer = renderer
+	e.screen, err = renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_TARGET, 64, 32)
 	if err != nil {
-		fmt.Fprint(os.Stderr, "Failed to create surface: %s\n", err)
-		os.Exit(2)
+		renderer.Destroy()
+		window.Destroy()
+		sdl.Quit()
+		return fmt.Errorf("failed to create texture: %v", err)
+	}
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	return nil
+}
+
+// useDisplay draws in the window of another emulator, to switch games
+func (e *Emulator) useDisplay(other *Emulator) {
+	e.window, e.renderer, e.screen = other.window, other.renderer, other.screen
+	e.Overlay = other.Overlay
+	e.window.SetTitle(e.title())
+	e.shouldDraw = true
+}
+
+func (e *Emulator) title() string {
+	if e.Rom != nil && e.Rom.Title != "" {
+		return "CHIP-8 - " + e.Rom.Title
 	}
-	e.surface = surface
+	return "CHIP-8"
 }
 
 func (e *Emulator) DestroyDisplay() {
-	sdl.Quit()
+	e.closeDebugger()
+	e.screen.Destroy()
+	e.renderer.Destroy()
 	e.window.Destroy()
+	sdl.Quit()
+}
+
+// Overlays drawn over the scaled display
+const (
+	OverlayNone      = ""
+	OverlayGrid      = "grid"      // lines between the pixels
+	OverlayScanlines = "scanlines" // every other line of the window darkened
+)
+
+var overlays = []string{OverlayNone, OverlayGrid, OverlayScanlines}
+
+// checkOverlay returns an error for an unknown overlay
+func checkOverlay(overlay string) error {
+	for _, o := range overlays {
+		if o == overlay {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown overlay %q, expected %s or %s", overlay, OverlayGrid, OverlayScanlines)
+}
+
+// letterbox returns where to draw a width x height image in a window of
+// w x h, as large as possible with its aspect ratio, at an integer scale
+// when the window is large enough
+func letterbox(w, h, width, height int32) sdl.Rect {
+	scaled := sdl.Rect{W: w, H: w * height / width}
+	if scaled.H > h {
+		scaled = sdl.Rect{W: h * width / height, H: h}
+	}
+	if scale := scaled.W / width; scale >= 1 {
+		scaled.W, scaled.H = width*scale, height*scale
+	}
+	scaled.X, scaled.Y = (w-scaled.W)/2, (h-scaled.H)/2
+	return scaled
+}
+
+// present clears the window and draws a texture of width x height
+// letterboxed in the area of the window, returning where it went
+func present(renderer *sdl.Renderer, texture *sdl.Texture, width, height int32, area sdl.Rect) sdl.Rect {
+	renderer.SetRenderTarget(nil)
+	renderer.SetDrawColor(0, 0, 0, 255)
+	renderer.Clear()
+	dst := letterbox(area.W, area.H, width, height)
+	dst.X, dst.Y = dst.X+area.X, dst.Y+area.Y
+	renderer.Copy(texture, nil, &dst)
+	return dst
+}
+
+// windowArea returns the area of the whole window
+func windowArea(renderer *sdl.Renderer) sdl.Rect {
+	w, h, _ := renderer.GetOutputSize()
+	return sdl.Rect{X: 0, Y: 0, W: w, H: h}
 }
 
 func (e *Emulator) draw() {
+	e.renderer.SetRenderTarget(e.screen)
 	for i := range e.Gfx {
-		x := int32(i % 64)
-		y := int32(int(i / 64))
-		rect := sdl.Rect{x * 10, y * 10, 10, 10}
-		if e.Gfx[i] == 1 {
-			e.surface.FillRect(&rect, sdl.MapRGB(e.surface.Format, 200, 200, 200))
-		} else {
-			e.surface.FillRect(&rect, sdl.MapRGB(e.surface.Format, 35, 35, 35))
+		color := e.Palette.Color(e.Gfx[i])
+		if e.Persistence != nil {
+			color = e.Persistence.Color(i, e.Palette)
+		}
+		fillRect(e.renderer, sdl.Rect{X: int32(i % 64), Y: int32(i / 64), W: 1, H: 1}, color, 255)
+	}
+	area := windowArea(e.renderer)
+	var panel sdl.Rect
+	var lines []string
+	var hudFont int32
+	if e.hud != nil {
+		// the HUD takes the right of the window, beside the display
+		lines = e.hud.Lines(e)
+		hudFont = hudScale(area.H, len(lines))
+		panel = sdl.Rect{X: area.W - hudWidth(hudFont), Y: 0, W: hudWidth(hudFont), H: area.H}
+		area.W = panel.X
+	}
+	dst := present(e.renderer, e.screen, 64, 32, area)
+	if e.hud != nil {
+		e.drawHUD(panel, lines, hudFont)
+	}
+	e.drawOverlay(dst)
+	if status := e.status(); status != "" {
+		e.drawStatus(dst, status)
+	}
+	if e.toastFrames > 0 {
+		e.drawToast(dst)
+	}
+	e.renderer.Present()
+	e.shouldDraw = false
+}
+
+// drawOverlay draws the grid or the scanlines over the display drawn in dst
+func (e *Emulator) drawOverlay(dst sdl.Rect) {
+	scale := dst.W / 64
+	if scale < 3 {
+		return
+	}
+	e.renderer.SetDrawColor(0, 0, 0, 96)
+	switch e.Overlay {
+	case OverlayGrid:
+		for x := int32(0); x <= 64; x++ {
+			e.renderer.DrawLine(dst.X+x*scale, dst.Y, dst.X+x*scale, dst.Y+dst.H-1)
+		}
+		for y := int32(0); y <= 32; y++ {
+			e.renderer.DrawLine(dst.X, dst.Y+y*scale, dst.X+dst.W-1, dst.Y+y*scale)
+		}
+	case OverlayScanlines:
+		for y := dst.Y; y < dst.Y+dst.H; y += 2 {
+			e.renderer.DrawLine(dst.X, y, dst.X+dst.W-1, y)
+		}
+	}
+}
+
+// nextOverlay switches to the following overlay
+func (e *Emulator) nextOverlay() string {
+	next := overlays[0]
+	for i, overlay := range overlays {
+		if overlay == e.Overlay {
+			next = overlays[(i+1)%len(overlays)]
 		}
 	}
-	e.window.UpdateSurface()
+	e.Overlay = next
+	e.shouldDraw = true
+	if next == OverlayNone {
+		return "none"
+	}
+	return next
+}
+
+// toggleFullscreen switches between the window and the full screen
+func (e *Emulator) toggleFullscreen() {
+	e.Fullscreen = e.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP != sdl.WINDOW_FULLSCREEN_DESKTOP
+	if e.Fullscreen {
+		e.window.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
+	} else {
+		e.window.SetFullscreen(0)
+	}
+	e.shouldDraw = true
+}
+
+// Toast shows a message at the bottom of the display for a few seconds
+func (e *Emulator) Toast(text string) {
+	e.toast = text
+	e.toastFrames = 3 * 60
+	e.shouldDraw = true
+}
+
+// drawStatus shows the state of the emulation in the top right corner
+func (e *Emulator) drawStatus(dst sdl.Rect, status string) {
+	scale := dst.W / 200
+	if scale < 1 {
+		scale = 1
+	}
+	width := TextWidth(status, scale) + 3*scale
+	banner := sdl.Rect{X: dst.X + dst.W - width, Y: dst.Y, W: width, H: (glyphHeight + 2) * scale}
+	fillRect(e.renderer, banner, Color{0, 0, 0}, 160)
+	drawText(e.renderer, banner.X+2*scale, banner.Y+2*scale, scale, status, Color{255, 255, 255})
+}
+
+func (e *Emulator) drawToast(dst sdl.Rect) {
+	scale := dst.W / 200
+	if scale < 1 {
+		scale = 1
+	}
+	height := (glyphHeight + 2) * scale
+	banner := sdl.Rect{X: dst.X, Y: dst.Y + dst.H - height, W: dst.W, H: height}
+	fillRect(e.renderer, banner, Color{0, 0, 0}, 255)
+	x := dst.X + (dst.W-TextWidth(e.toast, scale))/2
+	drawText(e.renderer, x, banner.Y+2*scale, scale, e.toast, Color{255, 220, 0})
 }
 
 func (e *Emulator) next() {
@@ -156,380 +402,619 @@ func (e *Emulator) jump(next uint16) {
 	e.Pc = next
 }
 
-func (e *Emulator) nnn(opcode uint16) uint16 {
-	return opcode & 0x0FFF
-}
-
-func (e *Emulator) nn(opcode uint16) uint16 {
-	return opcode & 0x00FF
-}
-
-func (e *Emulator) x(opcode uint16) uint16 {
-	return opcode & 0x0F00 >> 8
+// store writes a byte to memory, recording it for the tracers
+func (e *Emulator) store(addr uint16, value uint8) {
+	addr &= 0x0FFF
+	if len(e.tracers) > 0 {
+		e.writes = append(e.writes, MemWrite{Addr: addr, Old: e.Memory[addr], New: value})
+	}
+	if e.executed[addr] {
+		e.memoryEvent(CodeOverwritten, addr)
+	}
+	e.written[addr] = true
+	e.fresh[addr] = true
+	e.Memory[addr] = value
+	if e.cache != nil {
+		e.cache.invalidate(addr)
+	}
 }
 
-func (e *Emulator) y(opcode uint16) uint16 {
-	return opcode & 0x00F0 >> 4
+// Load copies a ROM into memory at the program counter and applies its
+// settings from the ROM database, see IdentifyRom. The patches are applied
+// in order to a copy of the ROM, which is identified before patching.
+func (e *Emulator) Load(data []byte, patches ...Patch) error {
+	info := IdentifyRom(data)
+	for _, p := range patches {
+		var err error
+		if data, err = p.Apply(data); err != nil {
+			return err
+		}
+	}
+	if int(e.Pc)+len(data) > len(e.Memory) {
+		return fmt.Errorf("ROM of %d bytes does not fit in memory", len(data))
+	}
+	for i, b := range data {
+		e.Memory[int(e.Pc)+i] = b
+	}
+	e.rom, e.romStart = append([]byte(nil), data...), e.Pc
+	if e.cache != nil {
+		e.cache.reset()
+	}
+	e.configure(info)
+	return nil
 }
 
-func (e *Emulator) Load(filepath string) {
-	file, err := os.Open(filepath)
+// LoadFile loads a ROM file, which may be compressed, see ReadRom
+func (e *Emulator) LoadFile(filepath string) error {
+	rom, err := ReadRom(filepath, "")
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
-	defer file.Close()
+	return e.Load(rom)
+}
 
-	stat, err := file.Stat()
+// RomFiles lists the files of a ROM directory, skipping its subdirectories
+func RomFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
-	fmt.Printf("file size:%v\n", stat.Size())
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return paths, nil
+}
 
-	buf := make([]byte, stat.Size())
-	err = binary.Read(file, binary.BigEndian, &buf)
-	if err != nil {
-		log.Fatalln(err)
+func (e *Emulator) Fetch() uint16 {
+	op1 := uint16(e.Memory[e.Pc&0x0FFF])
+	op2 := uint16(e.Memory[(e.Pc+1)&0x0FFF])
+	return op1<<8 | op2
+}
+
+// Step fetches and executes one instruction and reports it to the tracers.
+// Once an instruction failed, Step keeps returning its error.
+func (e *Emulator) Step() error {
+	if e.err != nil {
+		return e.err
+	}
+	if int(e.Pc)+1 >= len(e.Memory) {
+		e.err = &PcError{Pc: e.Pc}
+		return e.err
+	}
+	var in instruction
+	if e.cache != nil {
+		in = e.cache.lookup(e.Pc, &e.Memory)
+	} else {
+		in = decode(e.Fetch())
+	}
+	e.Opcode = in.opcode
+	e.events = e.events[:0]
+	e.markExecuted(e.Pc)
+	if len(e.tracers) == 0 {
+		in.run(e, in)
+		e.Cycle++
+		return e.err
 	}
 
-	for i, b := range buf {
-		e.Memory[int(e.Pc)+i] = b
-		fmt.Printf("%x", e.Memory[int(e.Pc)+i])
+	r := TraceRecord{Cycle: e.Cycle, Pc: e.Pc, Opcode: in.opcode}
+	before := e.V
+	e.writes = e.writes[:0]
+	in.run(e, in)
+	e.Cycle++
+
+	for i := range e.V {
+		if e.V[i] != before[i] {
+			r.Regs = append(r.Regs, RegChange{Reg: uint8(i), Old: before[i], New: e.V[i]})
+		}
+	}
+	r.Writes = append(r.Writes, e.writes...)
+	r.Events = append(r.Events, e.events...)
+	r.I = e.I
+	r.Sp = e.Sp
+	r.Next = e.Pc
+	for _, t := range e.tracers {
+		t.Trace(&r)
 	}
+	return e.err
 }
 
-func (e *Emulator) Fetch() uint16 {
-	op1 := uint16(e.Memory[int(e.Pc)])
-	op2 := uint16(e.Memory[int(e.Pc)+1])
-	return op1<<8 | op2
+// instruction is an opcode decoded into its handler and operands
+type instruction struct {
+	run    func(e *Emulator, in instruction)
+	opcode uint16
+	x      uint16
+	y      uint16
+	n      uint16
+	nn     uint16
+	nnn    uint16
 }
 
-func (e *Emulator) Exec(opcode uint16) {
+// decode returns the instruction of an opcode
+func decode(opcode uint16) instruction {
 	// https://github.com/mattmikolay/chip-8/wiki/CHIP%E2%80%908-Instruction-Set
+	in := instruction{
+		opcode: opcode,
+		x:      opcode & 0x0F00 >> 8,
+		y:      opcode & 0x00F0 >> 4,
+		n:      opcode & 0x000F,
+		nn:     opcode & 0x00FF,
+		nnn:    opcode & 0x0FFF,
+	}
+	in.run = opUnknown
 	switch opcode & 0xF000 {
 	case 0x0000:
 		switch opcode & 0x00FF {
 		case 0x00E0:
-			// CLS: Clear the screen
-			e.Gfx = [2048]uint8{}
-			e.shouldDraw = true
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op00E0
 		case 0x00EE:
-			e.Sp--
-			//e.Pc = e.Stack[e.Sp]
-			e.jump(e.Stack[e.Sp])
-			e.next()
-		default:
-			log.Fatalf("Unexpected opcode 0x%x\n", opcode)
+			in.run = op00EE
 		}
 	case 0x1000:
-		// Goto NNN: Jump to address NNN
-		//e.Pc = e.nnn(opcode)
-		e.jump(e.nnn(opcode))
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op1NNN
 	case 0x2000:
-		// CALL: Call the subroutine at address NNN
-		e.Stack[e.Sp] = e.Pc
-		e.Sp++
-		//e.Pc = e.nnn(opcode)
-		e.jump(e.nnn(opcode))
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op2NNN
 	case 0x3000:
-		// skips the next instruction if VX equals NN.
-		// (Usually the next instruction is a jump to skip a code block)
-		x := e.x(opcode)
-		log.Printf("VF: %v\n", e.V[x])
-		log.Printf("NN: %v\n", e.nn(opcode))
-		if int(e.V[x]) == int(e.nn(opcode)) {
-			e.skip()
-		} else {
-			e.next()
-		}
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op3XNN
 	case 0x4000:
-		// skips the next instruction if VX doesn't equal NN.
-		// (Usually the next instruction is a jump to skip a code block)
-		x := e.x(opcode)
-		if int(e.V[x]) != int(e.nn(opcode)) {
-			e.skip()
-		} else {
-			e.next()
-		}
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op4XNN
 	case 0x5000:
-		// skips the next instruction if VX equals VY.
-		// (Usually the next instruction is a jump to skip a code block)
-		x := e.x(opcode)
-		y := e.y(opcode)
-		if e.V[x] == e.V[y] {
-			e.skip()
-		} else {
-			e.next()
-		}
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op5XY0
 	case 0x6000:
-		// Sets VX to NN.
-		x := e.x(opcode)
-		if x == 0 && uint8(e.nn(opcode)) == 1 {
-			log.Printf("VX %v", uint8(e.nn(opcode)))
-		}
-		e.V[x] = uint8(e.nn(opcode))
-		e.next()
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op6XNN
 	case 0x7000:
-		// 	Adds NN to VX. (Carry flag is not changed)
-		x := e.x(opcode)
-		e.V[x] += uint8(e.nn(opcode))
-		e.next()
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op7XNN
 	case 0x8000:
 		switch opcode & 0x000F {
 		case 0:
-			// Sets VX to the value of VY.
-			x := e.x(opcode)
-			y := e.y(opcode)
-			e.V[x] = e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY0
 		case 1:
-			// 	Sets VX to VX or VY. (Bitwise OR operation)
-			x := e.x(opcode)
-			y := e.y(opcode)
-			e.V[x] = e.V[x] | e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY1
 		case 2:
-			// Sets VX to VX and VY. (Bitwise AND operation)
-			x := e.x(opcode)
-			y := e.y(opcode)
-			e.V[x] = e.V[x] & e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY2
 		case 3:
-			// Sets VX to VX xor VY.
-			x := e.x(opcode)
-			y := e.y(opcode)
-			e.V[x] = e.V[x] ^ e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY3
 		case 4:
-			// Add the value of register VY to register VX
-			// Set VF to 01 if a carry occurs
-			// Set VF to 00 if a carry does not occur
-			x := e.x(opcode)
-			y := e.y(opcode)
-			if uint16(e.V[x])+uint16(e.V[y]) > 0xFF {
-				e.V[0xF] = 0x1
-			} else {
-				e.V[0xF] = 0x0
-			}
-			e.V[x] += e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY4
 		case 5:
-			// Subtract the value of register VY from register VX
-			// Set VF to 00 if a borrow occurs
-			// Set VF to 01 if a borrow does not occur
-			x := e.x(opcode)
-			y := e.y(opcode)
-			if e.V[x] < e.V[y] {
-				e.V[0xF] = 0x0
-			} else {
-				e.V[0xF] = 0x1
-			}
-			e.V[x] -= e.V[y]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY5
 		case 6:
-			// Store the value of register VY shifted right one bit in register VX¹
-			// Set register VF to the least significant bit prior to the shift
-			// VY is unchanged
-			x := e.x(opcode)
-			if (e.V[x] & 0x01) == 1 {
-				e.V[0xF] = 0x1
-			} else {
-				e.V[0xF] = 0x0
-			}
-			e.V[x] >>= 1
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY6
 		case 7:
-			// Set register VX to the value of VY minus VX
-			// Set VF to 00 if a borrow occurs
-			// Set VF to 01 if a borrow does not occur
-			x := e.x(opcode)
-			y := e.y(opcode)
-			if e.V[y]-e.V[x] < 0 {
-				e.V[0xF] = 0x0
-			} else {
-				e.V[0xF] = 0x1
-			}
-			e.V[x] = e.V[y] - e.V[x]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = op8XY7
 		case 0xE:
-			// Store the value of register VY shifted left one bit in register VX¹
-			// Set register VF to the most significant bit prior to the shift
-			// VY is unchanged
-			x := e.x(opcode)
-			if e.V[x]>>7 == 1 {
-				e.V[0xF] = 0x1
-			} else {
-				e.V[0xF] = 0x0
-			}
-			e.V[x] <<= 1
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
-		default:
-			log.Fatalf("Unexpected opcode 0x%x\n", opcode)
+			in.run = op8XYE
 		}
 	case 0x9000:
-		x := e.x(opcode)
-		y := e.y(opcode)
-		if e.V[x] != e.V[y] {
-			e.skip()
-		} else {
-			e.next()
-		}
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = op9XY0
 	case 0xA000:
-		// LD: Sets I to the address NNN.
-		e.I = e.nnn(opcode)
-		e.next()
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = opANNN
 	case 0xB000:
-		//e.Pc = e.nnn(opcode) + uint16(e.V[0])
-		e.jump(e.nnn(opcode) + uint16(e.V[0]))
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = opBNNN
 	case 0xC000:
-		x := e.x(opcode)
-		mask := e.nn(opcode)
-		e.V[x] = uint8(rand.Uint32() & uint32(mask))
-		e.next()
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = opCXNN
 	case 0xD000:
-		vx := e.V[e.x(opcode)]
-		vy := e.V[e.y(opcode)]
-		height := opcode & 0x000F
-		e.V[0xF] = 0
-		for yi := 0; yi < int(height); yi++ {
-			row := e.Memory[int(e.I)+yi]
-			for xi := 0; xi < 8; xi++ {
-				// 1000 0000 >> xi
-				if row&(0x80>>uint8(xi)) != 0 {
-					x := int(vx) + xi
-					y := int(vy) + yi
-					// allow for wrapping
-					// https://www.reddit.com/r/EmuDev/comments/aar9nb/chip_8_emulator_collision_detection_not_working/
-					if x >= 64 {
-						x %= 64
-					}
-					if y >= 32 {
-						y %= 32
-					}
-					if e.Gfx[x+y*64] == 1 {
-						// when collision detected
-						e.V[0xF] = 1
-					} else {
-						e.V[0xF] = 0
-					}
-					e.Gfx[x+y*64] ^= 1
-				}
-			}
-		}
-		e.shouldDraw = true
-		e.next()
-		log.Printf("Exec opcode 0x%x\n", opcode)
+		in.run = opDXYN
 	case 0xE000:
 		switch opcode & 0x00FF {
 		case 0x9E:
-			x := e.x(opcode)
-			key := byte(e.V[x])
-			if e.pressed(key) {
-				e.skip()
-			} else {
-				e.next()
-			}
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opEX9E
 		case 0xA1:
-			x := e.x(opcode)
-			key := byte(e.V[x])
-			if !e.pressed(key) {
-				e.skip()
-			} else {
-				e.next()
-			}
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opEXA1
+		default:
+			in.run = opNop
 		}
 	case 0xF000:
 		switch opcode & 0x00FF {
 		case 0x07:
-			x := e.x(opcode)
-			e.V[x] = e.delayTimer
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX07
 		case 0x0A:
-			pressed := false
-			for i, v := range e.keys {
-				if v {
-					x := e.x(opcode)
-					e.V[x] = byte(i)
-					pressed = true
-				}
-			}
-			if pressed {
-				e.next()
-			}
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX0A
 		case 0x15:
-			x := e.x(opcode)
-			e.delayTimer = e.V[x]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX15
 		case 0x18:
-			x := e.x(opcode)
-			e.soundTimer = e.V[x]
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX18
 		case 0x1E:
-			x := e.x(opcode)
-			e.I += uint16(e.V[x])
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX1E
 		case 0x29:
-			// 0xFX29 Sets I to the location of the sprite for the character in VX.
-			// Characters 0-F (in hexadecimal) are represented by a 4x5 font
-			vx := e.V[e.x(opcode)]
-			e.I = uint16(vx) * 5
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX29
 		case 0x33:
-			x := e.x(opcode)
-			e.Memory[e.I] = e.V[x] / 100
-			e.Memory[e.I+1] = (e.V[x] / 10) % 10
-			e.Memory[e.I+2] = e.V[x] % 10
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX33
 		case 0x55:
-			x := e.x(opcode)
-			for i := 0; i < int(x)+1; i++ {
-				e.Memory[int(e.I)+i] = e.V[i]
-			}
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
+			in.run = opFX55
 		case 0x65:
-			x := e.x(opcode)
-			for i := 0; i < int(x)+1; i++ {
-				e.V[i] = e.Memory[int(e.I)+i]
+			in.run = opFX65
+		}
+	}
+	return in
+}
+
+// Exec executes an opcode
+func (e *Emulator) Exec(opcode uint16) {
+	in := decode(opcode)
+	in.run(e, in)
+}
+
+// UnknownOpcodeError is returned by Step for an opcode the emulator does not implement
+type UnknownOpcodeError struct {
+	Pc     uint16
+	Opcode uint16
+}
+
+func (err *UnknownOpcodeError) Error() string {
+	return fmt.Sprintf("unexpected opcode 0x%04x at 0x%03x", err.Opcode, err.Pc)
+}
+
+func opUnknown(e *Emulator, in instruction) {
+	e.err = &UnknownOpcodeError{Pc: e.Pc, Opcode: in.opcode}
+}
+
+// PcError is returned by Step when the program counter leaves the memory
+type PcError struct {
+	Pc uint16
+}
+
+func (err *PcError) Error() string {
+	return fmt.Sprintf("program counter 0x%04x outside the memory", err.Pc)
+}
+
+// StackError is returned by Step for a call with a full stack or a return
+// with an empty one
+type StackError struct {
+	Pc       uint16
+	Opcode   uint16
+	Overflow bool
+}
+
+func (err *StackError) Error() string {
+	if err.Overflow {
+		return fmt.Sprintf("stack overflow by opcode 0x%04x at 0x%03x", err.Opcode, err.Pc)
+	}
+	return fmt.Sprintf("stack underflow by opcode 0x%04x at 0x%03x", err.Opcode, err.Pc)
+}
+
+func opNop(e *Emulator, in instruction) {
+}
+
+func op00E0(e *Emulator, in instruction) {
+	// CLS: Clear the screen
+	e.Gfx = [2048]uint8{}
+	e.shouldDraw = true
+	e.next()
+}
+
+func op00EE(e *Emulator, in instruction) {
+	if e.Sp == 0 {
+		e.err = &StackError{Pc: e.Pc, Opcode: in.opcode}
+		return
+	}
+	e.Sp--
+	e.jump(e.Stack[e.Sp])
+	e.next()
+}
+
+func op1NNN(e *Emulator, in instruction) {
+	// Goto NNN: Jump to address NNN
+	e.jump(in.nnn)
+}
+
+func op2NNN(e *Emulator, in instruction) {
+	// CALL: Call the subroutine at address NNN
+	if int(e.Sp) >= len(e.Stack) {
+		e.err = &StackError{Pc: e.Pc, Opcode: in.opcode, Overflow: true}
+		return
+	}
+	e.Stack[e.Sp] = e.Pc
+	e.Sp++
+	e.jump(in.nnn)
+}
+
+func op3XNN(e *Emulator, in instruction) {
+	// skips the next instruction if VX equals NN.
+	// (Usually the next instruction is a jump to skip a code block)
+	if uint16(e.V[in.x]) == in.nn {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func op4XNN(e *Emulator, in instruction) {
+	// skips the next instruction if VX doesn't equal NN.
+	// (Usually the next instruction is a jump to skip a code block)
+	if uint16(e.V[in.x]) != in.nn {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func op5XY0(e *Emulator, in instruction) {
+	// skips the next instruction if VX equals VY.
+	// (Usually the next instruction is a jump to skip a code block)
+	if e.V[in.x] == e.V[in.y] {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func op6XNN(e *Emulator, in instruction) {
+	// Sets VX to NN.
+	e.V[in.x] = uint8(in.nn)
+	e.next()
+}
+
+func op7XNN(e *Emulator, in instruction) {
+	// 	Adds NN to VX. (Carry flag is not changed)
+	e.V[in.x] += uint8(in.nn)
+	e.next()
+}
+
+func op8XY0(e *Emulator, in instruction) {
+	// Sets VX to the value of VY.
+	e.V[in.x] = e.V[in.y]
+	e.next()
+}
+
+func op8XY1(e *Emulator, in instruction) {
+	// 	Sets VX to VX or VY. (Bitwise OR operation)
+	e.V[in.x] = e.V[in.x] | e.V[in.y]
+	if e.Quirks.ResetVF {
+		e.V[0xF] = 0
+	}
+	e.next()
+}
+
+func op8XY2(e *Emulator, in instruction) {
+	// Sets VX to VX and VY. (Bitwise AND operation)
+	e.V[in.x] = e.V[in.x] & e.V[in.y]
+	if e.Quirks.ResetVF {
+		e.V[0xF] = 0
+	}
+	e.next()
+}
+
+func op8XY3(e *Emulator, in instruction) {
+	// Sets VX to VX xor VY.
+	e.V[in.x] = e.V[in.x] ^ e.V[in.y]
+	if e.Quirks.ResetVF {
+		e.V[0xF] = 0
+	}
+	e.next()
+}
+
+func op8XY4(e *Emulator, in instruction) {
+	// Add the value of register VY to register VX
+	// Set VF to 01 if a carry occurs
+	// Set VF to 00 if a carry does not occur
+	x, y := in.x, in.y
+	if uint16(e.V[x])+uint16(e.V[y]) > 0xFF {
+		e.V[0xF] = 0x1
+	} else {
+		e.V[0xF] = 0x0
+	}
+	e.V[x] += e.V[y]
+	e.next()
+}
+
+func op8XY5(e *Emulator, in instruction) {
+	// Subtract the value of register VY from register VX
+	// Set VF to 00 if a borrow occurs
+	// Set VF to 01 if a borrow does not occur
+	x, y := in.x, in.y
+	if e.V[x] < e.V[y] {
+		e.V[0xF] = 0x0
+	} else {
+		e.V[0xF] = 0x1
+	}
+	e.V[x] -= e.V[y]
+	e.next()
+}
+
+func op8XY6(e *Emulator, in instruction) {
+	// Store the value of register VY shifted right one bit in register VX¹
+	// Set register VF to the least significant bit prior to the shift
+	// VY is unchanged
+	x := in.x
+	if e.Quirks.ShiftVY {
+		e.V[x] = e.V[in.y]
+	}
+	if (e.V[x] & 0x01) == 1 {
+		e.V[0xF] = 0x1
+	} else {
+		e.V[0xF] = 0x0
+	}
+	e.V[x] >>= 1
+	e.next()
+}
+
+func op8XY7(e *Emulator, in instruction) {
+	// Set register VX to the value of VY minus VX
+	// Set VF to 00 if a borrow occurs
+	// Set VF to 01 if a borrow does not occur
+	x, y := in.x, in.y
+	if e.V[y]-e.V[x] < 0 {
+		e.V[0xF] = 0x0
+	} else {
+		e.V[0xF] = 0x1
+	}
+	e.V[x] = e.V[y] - e.V[x]
+	e.next()
+}
+
+func op8XYE(e *Emulator, in instruction) {
+	// Store the value of register VY shifted left one bit in register VX¹
+	// Set register VF to the most significant bit prior to the shift
+	// VY is unchanged
+	x := in.x
+	if e.Quirks.ShiftVY {
+		e.V[x] = e.V[in.y]
+	}
+	if e.V[x]>>7 == 1 {
+		e.V[0xF] = 0x1
+	} else {
+		e.V[0xF] = 0x0
+	}
+	e.V[x] <<= 1
+	e.next()
+}
+
+func op9XY0(e *Emulator, in instruction) {
+	if e.V[in.x] != e.V[in.y] {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func opANNN(e *Emulator, in instruction) {
+	// LD: Sets I to the address NNN.
+	e.I = in.nnn
+	e.next()
+}
+
+func opBNNN(e *Emulator, in instruction) {
+	if e.Quirks.JumpVX {
+		e.jump(in.nnn + uint16(e.V[in.x]))
+		return
+	}
+	e.jump(in.nnn + uint16(e.V[0]))
+}
+
+func opCXNN(e *Emulator, in instruction) {
+	e.V[in.x] = uint8(e.rand.Uint32() & uint32(in.nn))
+	e.next()
+}
+
+func opDXYN(e *Emulator, in instruction) {
+	vx := e.V[in.x]
+	vy := e.V[in.y]
+	height := in.n
+	e.V[0xF] = 0
+	for yi := 0; yi < int(height); yi++ {
+		row := e.Memory[(int(e.I)+yi)&0x0FFF]
+		for xi := 0; xi < 8; xi++ {
+			// 1000 0000 >> xi
+			if row&(0x80>>uint8(xi)) != 0 {
+				x := int(vx) + xi
+				y := int(vy) + yi
+				if e.Quirks.ClipSprites {
+					// the sprite starts wrapped but is clipped at the edges
+					x = int(vx)%64 + xi
+					y = int(vy)%32 + yi
+					if x >= 64 || y >= 32 {
+						continue
+					}
+				}
+				// allow for wrapping
+				// https://www.reddit.com/r/EmuDev/comments/aar9nb/chip_8_emulator_collision_detection_not_working/
+				if x >= 64 {
+					x %= 64
+				}
+				if y >= 32 {
+					y %= 32
+				}
+				if e.Gfx[x+y*64] == 1 {
+					// when collision detected
+					e.V[0xF] = 1
+				} else {
+					e.V[0xF] = 0
+				}
+				e.Gfx[x+y*64] ^= 1
 			}
-			e.next()
-			log.Printf("Exec opcode 0x%x\n", opcode)
-		default:
-			log.Fatalf("Unexpected opcode 0x%x\n", opcode)
 		}
-	default:
-		log.Fatalf("Unexpected opcode 0x%x\n", opcode)
 	}
+	e.shouldDraw = true
+	e.next()
+}
+
+func opEX9E(e *Emulator, in instruction) {
+	key := byte(e.V[in.x])
+	if e.pressed(key) {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func opEXA1(e *Emulator, in instruction) {
+	key := byte(e.V[in.x])
+	if !e.pressed(key) {
+		e.skip()
+	} else {
+		e.next()
+	}
+}
+
+func opFX07(e *Emulator, in instruction) {
+	e.V[in.x] = e.delayTimer
+	e.next()
+}
+
+func opFX0A(e *Emulator, in instruction) {
+	pressed := false
+	for i, v := range e.keys {
+		if v {
+			e.V[in.x] = byte(i)
+			pressed = true
+		}
+	}
+	if pressed {
+		e.next()
+	}
+}
+
+func opFX15(e *Emulator, in instruction) {
+	e.delayTimer = e.V[in.x]
+	e.next()
+}
+
+func opFX18(e *Emulator, in instruction) {
+	e.soundTimer = e.V[in.x]
+	e.next()
+}
+
+func opFX1E(e *Emulator, in instruction) {
+	e.I += uint16(e.V[in.x])
+	e.next()
+}
+
+func opFX29(e *Emulator, in instruction) {
+	// 0xFX29 Sets I to the location of the sprite for the character in VX.
+	// Characters 0-F (in hexadecimal) are represented by a 4x5 font
+	e.I = uint16(e.V[in.x]) * 5
+	e.next()
+}
+
+func opFX33(e *Emulator, in instruction) {
+	x := in.x
+	e.store(e.I, e.V[x]/100)
+	e.store(e.I+1, (e.V[x]/10)%10)
+	e.store(e.I+2, e.V[x]%10)
+	e.next()
+}
+
+func opFX55(e *Emulator, in instruction) {
+	for i := 0; i < int(in.x)+1; i++ {
+		e.store(e.I+uint16(i), e.V[i])
+	}
+	if e.Quirks.IncrementI {
+		e.I += uint16(in.x) + 1
+	}
+	e.next()
+}
+
+func opFX65(e *Emulator, in instruction) {
+	for i := 0; i < int(in.x)+1; i++ {
+		e.V[i] = e.Memory[(int(e.I)+i)&0x0FFF]
+	}
+	if e.Quirks.IncrementI {
+		e.I += uint16(in.x) + 1
+	}
+	e.next()
 }
 
 // Print Emulator status
@@ -560,36 +1045,162 @@ func (e *Emulator) pressedKey() byte {
 	return 0xff
 }
 
+func (e *Emulator) tickTimers() {
+	if e.delayTimer > 0 {
+		e.delayTimer--
+	}
+	e.beeping = e.soundTimer > 0
+	if e.soundTimer > 0 {
+		e.soundTimer--
+	}
+}
+
+// Frame executes the instructions of one 60Hz frame and ticks the timers
+func (e *Emulator) Frame() error {
+	for i := 0; i < e.Speed; i++ {
+		if err := e.Step(); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.frozen {
+		c.Apply(e)
+	}
+	e.tickTimers()
+	if e.OnFrame != nil {
+		e.OnFrame()
+	}
+	if e.recorder != nil {
+		if err := e.recorder.Capture(e); err != nil {
+			log.Printf("recording %s: %v\n", e.recorder.Path, err)
+			e.recorder = nil
+		}
+	}
+	return nil
+}
+
+// RunHeadless runs the emulator without display nor input for the given number of cycles
+func (e *Emulator) RunHeadless(cycles uint64) error {
+	for end := e.Cycle + cycles; e.Cycle < end; {
+		if err := e.Frame(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // https://github.com/veandco/go-sdl2-examples/blob/master/examples/keyboard-input/keyboard-input.go
 func (e *Emulator) Run() (err error) {
 
 	running := true
 	for running {
 
-		opcode := e.Fetch()
-		e.Exec(opcode)
-		if e.delayTimer > 0 {
-			e.delayTimer--
+		frames := e.framesToRun()
+		for i := 0; i < frames; i++ {
+			if err = e.Frame(); err != nil {
+				return
+			}
+			if e.Persistence != nil && e.Persistence.Update(&e.Gfx) {
+				e.shouldDraw = true
+			}
 		}
-		if e.soundTimer > 0 {
-			e.soundTimer--
+		if e.hud != nil {
+			e.hud.Measure(sdl.GetTicks(), frames, e.Cycle)
+			if frames > 0 {
+				e.shouldDraw = true
+			}
+		}
+		if e.toastFrames > 0 {
+			if e.toastFrames--; e.toastFrames == 0 {
+				e.shouldDraw = true
+			}
 		}
 		if e.shouldDraw {
 			e.draw()
 		}
+		if e.debugger != nil {
+			e.debugger.draw(e)
+		}
 		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
-			fmt.Println("event loop")
+			if e.debugger != nil {
+				if handled, closed := e.debugger.handleEvent(e, ev); closed {
+					e.closeDebugger()
+					continue
+				} else if handled {
+					continue
+				}
+			}
 			switch et := ev.(type) {
 			case *sdl.QuitEvent:
-				os.Exit(0)
+				running = false
+			case *sdl.WindowEvent:
+				// with the debugger open, closing the display does not quit
+				if et.Event == sdl.WINDOWEVENT_CLOSE {
+					running = false
+				}
+				e.shouldDraw = true
 			case *sdl.KeyboardEvent:
 				if et.Type == sdl.KEYUP {
-					fmt.Printf("keyup: %v", byte(et.Keysym.Scancode))
+					if et.Keysym.Scancode == sdl.SCANCODE_TAB {
+						e.turbo = false
+						e.shouldDraw = true
+					}
 					if v, ok := e.keyMap[int(et.Keysym.Scancode)]; ok {
 						e.keys[v] = false
 					}
 				} else if et.Type == sdl.KEYDOWN {
-					fmt.Printf("keydown: %v", byte(et.Keysym.Scancode))
+					switch et.Keysym.Scancode {
+					case sdl.SCANCODE_ESCAPE:
+						if e.escapable {
+							return errEscape
+						}
+					case sdl.SCANCODE_F1:
+						e.toggleHUD()
+					case sdl.SCANCODE_F2:
+						e.Toast("Palette: " + e.nextPalette())
+					case sdl.SCANCODE_F3:
+						if err := e.savePalette(); err != nil {
+							log.Println(err)
+							e.Toast("Palette not saved")
+						} else {
+							e.Toast("Palette saved")
+						}
+					case sdl.SCANCODE_F4:
+						e.Toast("Overlay: " + e.nextOverlay())
+					case sdl.SCANCODE_F5:
+						path := e.captureName(".png")
+						if err := e.SaveScreenshot(path); err != nil {
+							log.Println(err)
+							e.Toast("Screenshot failed")
+						} else {
+							e.Toast("Saved " + path)
+						}
+					case sdl.SCANCODE_F6:
+						e.toggleRecording(".gif")
+					case sdl.SCANCODE_F7:
+						e.toggleRecording(".y4m")
+					case sdl.SCANCODE_F8:
+						e.togglePause()
+					case sdl.SCANCODE_F9:
+						e.advance()
+					case sdl.SCANCODE_F10:
+						if et.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+							e.HardReset()
+							e.Toast("Hard reset")
+						} else {
+							e.Reset()
+							e.Toast("Reset")
+						}
+					case sdl.SCANCODE_F11:
+						e.toggleFullscreen()
+					case sdl.SCANCODE_F12:
+						e.slow = !e.slow
+						e.shouldDraw = true
+					case sdl.SCANCODE_TAB:
+						e.turbo = true
+						e.shouldDraw = true
+					case sdl.SCANCODE_GRAVE:
+						e.toggleDebugger()
+					}
 					if v, ok := e.keyMap[int(et.Keysym.Scancode)]; ok {
 						e.keys[v] = true
 					}
@@ -597,26 +1208,247 @@ func (e *Emulator) Run() (err error) {
 			}
 		}
 		// Chip8 cpu clock worked at frequency of 60Hz, so set delay to (1000/60)ms
-		sdl.Delay(1000 / 60)
+		sdl.Delay(e.frameDelay())
 
 	}
 
 	return
 }
 
+// commands are the subcommands of chip8, the default being to run a ROM
+var commands = map[string]func(args []string) error{
+	"analyze": runAnalyze,
+	"bench":   runBench,
+	"detect":  runDetect,
+	"env":     runEnv,
+}
+
+// singleRomFlags are the flags which need a ROM on the command line,
+// rejected with the launcher
+var singleRomFlags = map[string]bool{
+	"trace": true, "trace-format": true, "trace-pc": true, "trace-ops": true,
+	"profile": true, "pprof": true, "coverage": true, "coverage-format": true, "symbols": true,
+	"patch": true, "entry": true, "cycles": true, "screenshot": true, "record": true,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+	}
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run runs a ROM with the flags of the command line, returning through
+// the deferred calls so that the trace is flushed even on errors
+func run() error {
+	traceFile := flag.String("trace", "", "write an instruction trace to `file`")
+	traceFormat := flag.String("trace-format", "text", "trace output format: text or json")
+	tracePc := flag.String("trace-pc", "", "only trace instructions within a pc `range` such as 0x200-0x2FF")
+	traceOps := flag.String("trace-ops", "", "only trace the given opcode `classes` such as 1,2,D")
+	profileFile := flag.String("profile", "", "write a profile report with hotspots and annotated disassembly to `file`")
+	pprofFile := flag.String("pprof", "", "write a pprof compatible profile to `file`")
+	coverageFile := flag.String("coverage", "", "write a coverage report to `file`")
+	coverageFormat := flag.String("coverage-format", "lcov", "coverage report format: lcov or html")
+	symbolFile := flag.String("symbols", "", "map addresses to assembler source with a symbol map `file`")
+	smc := flag.Bool("smc", false, "log self-modifying code")
+	cached := flag.Bool("cached", false, "use the cached decode core instead of the interpreter")
+	headless := flag.Bool("headless", false, "run without display nor input")
+	cycles := flag.Uint64("cycles", 100000, "number of instructions to execute when headless")
+	quirks := flag.String("quirks", "", "override the quirks `profile` of the ROM: default, vip, schip or xochip")
+	speed := flag.Int("speed", 0, "override the instructions per frame of the ROM")
+	palette := flag.String("palette", "", "draw with a `palette`: gray, green, amber, lcd, contrast or colors such as #000000,#FFFFFF")
+	hold := flag.Int("hold", 0, "keep the pixels lit for `frames` after they are turned off, against flicker")
+	decay := flag.Float64("decay", 0, "then fade them out, keeping this part of their brightness every frame, at least 0 and below 1")
+	var patches Patches
+	flag.Var(&patches, "patch", "apply an IPS or BPS patch `file` to the ROM in memory, repeatable")
+	var pokes, freezes Cheats
+	flag.Var(&pokes, "poke", "set a `LOCATION=VALUE` such as 0x2F4=9 or VE=3 after loading, repeatable")
+	flag.Var(&freezes, "freeze", "set a `LOCATION=VALUE` every frame, repeatable")
+	achievementsFile := flag.String("achievements", "", "record the unlocked achievements in `file`, in the user configuration directory by default")
+	entry := flag.String("entry", "", "play the `file` of a zip archive holding several")
+	romDir := flag.String("roms", "roms", "list the ROMs of `dir` when no ROM is given")
+	scale := flag.Int("scale", 10, "initial window size, in window pixels per CHIP-8 `pixel`")
+	fullscreen := flag.Bool("fullscreen", false, "start in full screen, toggled with F11")
+	overlay := flag.String("overlay", "", "draw a `grid` or `scanlines` over the display, cycled with F4")
+	debug := flag.Bool("debug", false, "open the debugger window, toggled with the ` key")
+	hud := flag.Bool("hud", false, "show the speed, registers and pressed keys beside the display, toggled with F1")
+	captureScale := flag.Int("capture-scale", 4, "size of the screenshots and recordings, in image pixels per CHIP-8 `pixel`")
+	screenshot := flag.String("screenshot", "", "save the display as a PNG `file` at the end of the run")
+	record := flag.String("record", "", "record the run into a .gif or .y4m `file`, with the beep in a .wav file beside it, or the beep only into a .wav file")
+	seed := flag.Int64("seed", 0, "seed the random numbers of CXNN with `n` to replay a run, random when 0")
+	flag.Parse()
 
-	if len(os.Args) != 2 {
-		log.Fatalln("no ROM file")
+	if *scale < 1 || *captureScale < 1 {
+		return errors.New("the scales must be at least 1")
+	}
+	if *decay < 0 || *decay >= 1 {
+		return errors.New("the decay must be at least 0 and below 1")
+	}
+	if err := checkOverlay(*overlay); err != nil {
+		return err
+	}
+	setDisplay := func(e *Emulator) {
+		e.Scale, e.Fullscreen, e.Overlay = int32(*scale), *fullscreen, *overlay
+		e.CaptureScale = *captureScale
+		e.Debug = *debug
+		if *hud {
+			e.hud = &HUD{}
+		}
+	}
+	var gameQuirks Quirks
+	if *quirks != "" {
+		q, err := ParseQuirks(*quirks)
+		if err != nil {
+			return err
+		}
+		gameQuirks = q
 	}
-	filepath := os.Args[1]
+	var gamePalette Palette
+	if *palette != "" {
+		p, err := ParsePalette(*palette)
+		if err != nil {
+			return err
+		}
+		gamePalette = p
+	}
+	// setGame applies the flags of the games to an emulator with a ROM loaded
+	setGame := func(e *Emulator) {
+		if *seed != 0 {
+			e.Seed(*seed)
+		}
+		e.UseCachedCore(*cached)
+		if *smc {
+			e.OnMemoryEvent = func(ev MemoryEvent) {
+				log.Printf("cycle %d, pc %03X: %s\n", ev.Cycle, ev.Pc, ev)
+			}
+		}
+		if *quirks != "" {
+			e.Quirks = gameQuirks
+		}
+		if *speed > 0 {
+			e.Speed = *speed
+		}
+		if *palette != "" {
+			e.Palette = gamePalette
+		} else {
+			useSavedPalette(e)
+		}
+		for _, c := range pokes {
+			e.Poke(c)
+		}
+		for _, c := range freezes {
+			e.Freeze(c)
+		}
+		if *hold > 0 || *decay > 0 {
+			e.Persistence = &Persistence{Hold: *hold, Decay: *decay}
+		}
+		setDisplay(e)
+	}
+
+	if flag.NArg() == 0 && !*headless {
+		var single []string
+		flag.Visit(func(f *flag.Flag) {
+			if singleRomFlags[f.Name] {
+				single = append(single, "-"+f.Name)
+			}
+		})
+		if len(single) > 0 {
+			return fmt.Errorf("%s need a ROM", strings.Join(single, ", "))
+		}
+		return runLauncher(*romDir, *achievementsFile, setDisplay, setGame)
+	}
+	if flag.NArg() != 1 {
+		return errors.New("no ROM file")
+	}
+	filepath := flag.Arg(0)
 	fonts := NewFonts()
 	emu := NewEmulator(fonts)
-	emu.InitDisplay()
-	defer emu.DestroyDisplay()
-	emu.Load(filepath)
-	if err := emu.Run(); err != nil {
-		os.Exit(1)
+	if *traceFile != "" {
+		filter, err := ParseTraceFilter(*tracePc, *traceOps)
+		if err != nil {
+			return err
+		}
+		logger, err := CreateTraceLogger(*traceFile, *traceFormat, filter)
+		if err != nil {
+			return err
+		}
+		defer logger.Close()
+		emu.AddTracer(logger)
 	}
-
+	var profiler *Profiler
+	if *profileFile != "" || *pprofFile != "" {
+		profiler = NewProfiler()
+		emu.AddTracer(profiler)
+	}
+	var coverage *Coverage
+	if *coverageFile != "" {
+		coverage = NewCoverage()
+		if *symbolFile != "" {
+			symbols, err := LoadSymbolMap(*symbolFile)
+			if err != nil {
+				return err
+			}
+			coverage.Symbols = symbols
+		}
+		emu.AddTracer(coverage)
+	}
+	rom, err := ReadRom(filepath, *entry)
+	if err != nil {
+		return err
+	}
+	if err := emu.Load(rom, patches...); err != nil {
+		return err
+	}
+	if d := emu.Rom.Detected; d != nil && *quirks == "" {
+		log.Printf("unknown ROM, guessing %s with %s quirks (%.0f%% confident: %s), see -quirks\n",
+			d.Platform, d.Quirks, d.Confidence*100, strings.Join(d.Reasons, ", "))
+	}
+	setGame(emu)
+	if err := trackAchievements(emu, rom, *achievementsFile); err != nil {
+		log.Println(err)
+	}
+	if *record != "" {
+		if err := emu.StartRecording(*record); err != nil {
+			return err
+		}
+	}
+	if *headless {
+		err = emu.RunHeadless(*cycles)
+	} else {
+		if err := emu.InitDisplay(); err != nil {
+			return err
+		}
+		defer emu.DestroyDisplay()
+		if emu.Debug {
+			emu.toggleDebugger()
+		}
+		err = emu.Run()
+	}
+	if err := emu.StopRecording(); err != nil {
+		log.Println(err)
+	}
+	if *screenshot != "" {
+		if err := emu.SaveScreenshot(*screenshot); err != nil {
+			log.Println(err)
+		}
+	}
+	if profiler != nil {
+		if err := profiler.WriteProfileFiles(*profileFile, *pprofFile, emu); err != nil {
+			log.Println(err)
+		}
+	}
+	if coverage != nil {
+		if err := coverage.WriteFile(*coverageFile, *coverageFormat, filepath, emu); err != nil {
+			log.Println(err)
+		}
+	}
+	return err
 }
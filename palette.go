@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Color is an RGB color, written "#RRGGBB" in JSON
+type Color struct {
+	R, G, B uint8
+}
+
+// ParseColor parses a color such as "#C8C8C8"
+func ParseColor(s string) (Color, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return Color{}, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	rgb, err := strconv.ParseUint(s[1:], 16, 24)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	return Color{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb)}, nil
+}
+
+func (c Color) String() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// MarshalJSON implements json.Marshaler
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	color, err := ParseColor(s)
+	if err != nil {
+		return err
+	}
+	*c = color
+	return nil
+}
+
+// Palette are the colors of the pixel values: the background first, then
+// the lit pixels of the first plane, of the second plane and of both planes
+// for the multi-plane modes
+type Palette []Color
+
+// Color returns the color of a pixel value, the last color for the values
+// beyond the palette
+func (p Palette) Color(pixel uint8) Color {
+	if int(pixel) >= len(p) {
+		return p[len(p)-1]
+	}
+	return p[pixel]
+}
+
+func (p Palette) String() string {
+	var colors []string
+	for _, c := range p {
+		colors = append(colors, c.String())
+	}
+	return strings.Join(colors, ",")
+}
+
+// Palettes are the named palettes
+var Palettes = map[string]Palette{
+	"gray":     {{35, 35, 35}, {200, 200, 200}, {120, 120, 120}, {255, 255, 255}},
+	"green":    {{8, 24, 8}, {51, 255, 51}, {26, 128, 26}, {170, 255, 170}},
+	"amber":    {{26, 15, 0}, {255, 176, 0}, {128, 88, 0}, {255, 224, 160}},
+	"lcd":      {{155, 188, 15}, {15, 56, 15}, {48, 98, 48}, {139, 172, 15}},
+	"contrast": {{0, 0, 0}, {255, 255, 255}, {255, 255, 0}, {0, 255, 255}},
+}
+
+// DefaultPalette is the gray palette of the emulator
+var DefaultPalette = Palettes["gray"]
+
+// PaletteNames returns the names of the palettes in order
+func PaletteNames() []string {
+	var names []string
+	for name := range Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePalette returns a named palette, or parses the colors of a palette
+// such as "#000000,#FFFFFF"
+func ParsePalette(s string) (Palette, error) {
+	if p, ok := Palettes[s]; ok {
+		return p, nil
+	}
+	if !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("unknown palette %q, expected one of %s or colors such as #000000,#FFFFFF",
+			s, strings.Join(PaletteNames(), ", "))
+	}
+	var p Palette
+	for _, field := range strings.Split(s, ",") {
+		c, err := ParseColor(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	if len(p) < 2 {
+		return nil, fmt.Errorf("palette %q needs a background and a foreground color", s)
+	}
+	return p, nil
+}
+
+// nextPalette switches to the named palette following the current one
+func (e *Emulator) nextPalette() string {
+	names := PaletteNames()
+	next := names[0]
+	for i, name := range names {
+		if Palettes[name].String() == e.Palette.String() {
+			next = names[(i+1)%len(names)]
+		}
+	}
+	e.Palette = Palettes[next]
+	e.shouldDraw = true
+	return next
+}
+
+// SavedPalettes are the palettes chosen for ROMs, by SHA-1, in a JSON file
+type SavedPalettes struct {
+	path     string
+	palettes map[string]Palette
+}
+
+// DefaultSavedPalettesPath returns the file of the saved palettes in the user
+// configuration directory
+func DefaultSavedPalettesPath() (string, error) {
+	return configPath("palettes.json")
+}
+
+// LoadSavedPalettes reads the saved palettes, none when the file does not exist yet
+func LoadSavedPalettes(path string) (*SavedPalettes, error) {
+	s := &SavedPalettes{path: path, palettes: map[string]Palette{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.palettes); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Find returns the palette saved for a ROM, nil if none
+func (s *SavedPalettes) Find(rom string) Palette {
+	return s.palettes[rom]
+}
+
+// Save records the palette of a ROM in the file
+func (s *SavedPalettes) Save(rom string, p Palette) error {
+	s.palettes[rom] = p
+	data, err := json.MarshalIndent(s.palettes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// useSavedPalette switches to the palette saved for the loaded ROM, if any
+func useSavedPalette(e *Emulator) {
+	path, err := DefaultSavedPalettesPath()
+	if err != nil {
+		return
+	}
+	saved, err := LoadSavedPalettes(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if p := saved.Find(e.Rom.SHA1); len(p) > 0 {
+		e.Palette = p
+	}
+}
+
+// savePalette saves the current palette for the loaded ROM
+func (e *Emulator) savePalette() error {
+	if e.Rom == nil {
+		return fmt.Errorf("no ROM loaded")
+	}
+	path, err := DefaultSavedPalettesPath()
+	if err != nil {
+		return err
+	}
+	saved, err := LoadSavedPalettes(path)
+	if err != nil {
+		return err
+	}
+	return saved.Save(e.Rom.SHA1, e.Palette)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#FF9A3C")
+	if err != nil || c != (Color{0xFF, 0x9A, 0x3C}) || c.String() != "#FF9A3C" {
+		t.Errorf("got: %v %v,but expected: #FF9A3C", c, err)
+	}
+	if _, err := ParseColor("FF9A3C"); err == nil {
+		t.Errorf("got: no error,but expected: an invalid color error")
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	p, err := ParsePalette("amber")
+	if err != nil || p.String() != Palettes["amber"].String() {
+		t.Errorf("got: %v %v,but expected: amber", p, err)
+	}
+	p, err = ParsePalette("#000000, #FFFFFF")
+	if err != nil || p.String() != "#000000,#FFFFFF" {
+		t.Errorf("got: %v %v,but expected: #000000,#FFFFFF", p, err)
+	}
+	if actual := p.Color(3); actual != (Color{255, 255, 255}) {
+		t.Errorf("got: %v,but expected: the last color for the values beyond the palette", actual)
+	}
+	for _, s := range []string{"sepia", "#000000"} {
+		if _, err := ParsePalette(s); err == nil {
+			t.Errorf("got: no error for %q,but expected: an error", s)
+		}
+	}
+}
+
+func TestEmulator_NextPalette(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	expected := []string{"green", "lcd", "amber", "contrast", "gray"}
+	for _, name := range expected {
+		if actual := emu.nextPalette(); actual != name || emu.Palette.String() != Palettes[name].String() {
+			t.Errorf("got: %v,but expected: %v", actual, name)
+		}
+	}
+}
+
+func TestSavedPalettes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chip8", "palettes.json")
+	saved, err := LoadSavedPalettes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.Save("0000", Palettes["lcd"]); err != nil {
+		t.Fatal(err)
+	}
+	saved, err = LoadSavedPalettes(path)
+	if err != nil || saved.Find("0000").String() != Palettes["lcd"].String() || saved.Find("1111") != nil {
+		t.Errorf("got: %v %v,but expected: lcd saved for 0000", saved.palettes, err)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+)
+
+// Patch changes the bytes of a ROM in memory, leaving the ROM file untouched
+type Patch interface {
+	Apply(rom []byte) ([]byte, error)
+}
+
+// ReadPatch reads an IPS or BPS patch file
+func ReadPatch(path string) (Patch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("PATCH")):
+		return IPSPatch(data), nil
+	case bytes.HasPrefix(data, []byte("BPS1")):
+		return BPSPatch(data), nil
+	}
+	return nil, fmt.Errorf("%s is neither an IPS nor a BPS patch", path)
+}
+
+var errTruncatedPatch = errors.New("truncated patch")
+
+// IPSPatch is a patch in the IPS format: records of bytes to write at an offset
+type IPSPatch []byte
+
+// Apply implements Patch
+func (p IPSPatch) Apply(rom []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+	at := len("PATCH")
+	for {
+		if at+3 > len(p) {
+			return nil, errTruncatedPatch
+		}
+		if string(p[at:at+3]) == "EOF" {
+			at += 3
+			break
+		}
+		if at+5 > len(p) {
+			return nil, errTruncatedPatch
+		}
+		offset := int(p[at])<<16 | int(p[at+1])<<8 | int(p[at+2])
+		size := int(binary.BigEndian.Uint16(p[at+3:]))
+		at += 5
+		if size == 0 {
+			// run of a repeated byte
+			if at+3 > len(p) {
+				return nil, errTruncatedPatch
+			}
+			write(offset, bytes.Repeat(p[at+2:at+3], int(binary.BigEndian.Uint16(p[at:]))))
+			at += 3
+			continue
+		}
+		if at+size > len(p) {
+			return nil, errTruncatedPatch
+		}
+		write(offset, p[at:at+size])
+		at += size
+	}
+	// an optional size truncating the ROM
+	if at+3 <= len(p) {
+		if size := int(p[at])<<16 | int(p[at+1])<<8 | int(p[at+2]); size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// BPSPatch is a patch in the BPS format, checking the CRC32 of the ROM
+// before and after patching
+type BPSPatch []byte
+
+// bpsReader reads the variable length numbers of a BPS patch
+type bpsReader struct {
+	data []byte
+	at   int
+	end  int
+}
+
+var errBPSNumber = errors.New("BPS patch number out of range")
+
+// number reads a number, never negative: the ones overflowing an int are
+// rejected
+func (r *bpsReader) number() (int, error) {
+	n, shift := 0, 1
+	for {
+		if r.at >= r.end {
+			return 0, errTruncatedPatch
+		}
+		b := int(r.data[r.at])
+		r.at++
+		if n += (b & 0x7F) * shift; n < 0 {
+			return 0, errBPSNumber
+		}
+		if b&0x80 != 0 {
+			return n, nil
+		}
+		// the next group of 7 bits would pass 63 bits
+		if shift == 1<<56 {
+			return 0, errBPSNumber
+		}
+		shift <<= 7
+		if n += shift; n < 0 {
+			return 0, errBPSNumber
+		}
+	}
+}
+
+// offset reads a signed relative offset
+func (r *bpsReader) offset() (int, error) {
+	n, err := r.number()
+	if n&1 != 0 {
+		return -(n >> 1), err
+	}
+	return n >> 1, err
+}
+
+// Apply implements Patch
+func (p BPSPatch) Apply(rom []byte) ([]byte, error) {
+	if len(p) < len("BPS1")+12 {
+		return nil, errTruncatedPatch
+	}
+	footer := p[len(p)-12:]
+	if crc := crc32.ChecksumIEEE(p[:len(p)-4]); crc != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, fmt.Errorf("corrupted BPS patch: CRC32 %08x instead of %08x", crc, binary.LittleEndian.Uint32(footer[8:]))
+	}
+	if crc := crc32.ChecksumIEEE(rom); crc != binary.LittleEndian.Uint32(footer) {
+		return nil, fmt.Errorf("BPS patch for another ROM: CRC32 %08x instead of %08x", crc, binary.LittleEndian.Uint32(footer))
+	}
+
+	r := &bpsReader{data: p, at: len("BPS1"), end: len(p) - 12}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(rom) || targetSize > maxRom || metadataSize > r.end-r.at {
+		return nil, fmt.Errorf("BPS patch of a %d bytes ROM into %d bytes for a %d bytes ROM", sourceSize, targetSize, len(rom))
+	}
+	r.at += metadataSize
+
+	out := make([]byte, 0, targetSize)
+	sourceAt, targetAt := 0, 0
+	for r.at < r.end {
+		action, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		length := action>>2 + 1
+		if len(out)+length > targetSize {
+			return nil, fmt.Errorf("BPS patch writing past %d bytes", targetSize)
+		}
+		switch action & 3 {
+		case 0: // source read
+			if len(out)+length > len(rom) {
+				return nil, errTruncatedPatch
+			}
+			out = append(out, rom[len(out):len(out)+length]...)
+		case 1: // target read
+			if r.at+length > r.end {
+				return nil, errTruncatedPatch
+			}
+			out = append(out, p[r.at:r.at+length]...)
+			r.at += length
+		case 2: // source copy
+			offset, err := r.offset()
+			if err != nil {
+				return nil, err
+			}
+			if sourceAt += offset; sourceAt < 0 || sourceAt+length > len(rom) {
+				return nil, fmt.Errorf("BPS patch copying outside the ROM")
+			}
+			out = append(out, rom[sourceAt:sourceAt+length]...)
+			sourceAt += length
+		case 3: // target copy, byte per byte as the ranges may overlap
+			offset, err := r.offset()
+			if err != nil {
+				return nil, err
+			}
+			if targetAt += offset; targetAt < 0 || targetAt >= len(out) {
+				return nil, fmt.Errorf("BPS patch copying outside the patched ROM")
+			}
+			for i := 0; i < length; i++ {
+				out = append(out, out[targetAt])
+				targetAt++
+			}
+		}
+	}
+	if len(out) != targetSize {
+		return nil, fmt.Errorf("BPS patch made %d bytes instead of %d", len(out), targetSize)
+	}
+	if crc := crc32.ChecksumIEEE(out); crc != binary.LittleEndian.Uint32(footer[4:]) {
+		return nil, fmt.Errorf("BPS patch failed: CRC32 %08x instead of %08x", crc, binary.LittleEndian.Uint32(footer[4:]))
+	}
+	return out, nil
+}
+
+// Patches is a list of patch files given on the command line
+type Patches []Patch
+
+func (ps *Patches) String() string {
+	return fmt.Sprintf("%d patches", len(*ps))
+}
+
+// Set implements flag.Value, reading a patch file
+func (ps *Patches) Set(path string) error {
+	p, err := ReadPatch(strings.TrimSpace(path))
+	if err != nil {
+		return err
+	}
+	*ps = append(*ps, p)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestIPSPatch(t *testing.T) {
+	patch := []byte("PATCH")
+	patch = append(patch, 0, 0, 1, 0, 2, 0xAA, 0xBB) // 2 bytes at 1
+	patch = append(patch, 0, 0, 6, 0, 0, 0, 3, 0xCC) // 3 times 0xCC at 6, past the end
+	patch = append(patch, []byte("EOF")...)
+	rom := []byte{1, 2, 3, 4, 5}
+	actual, err := IPSPatch(patch).Apply(rom)
+	expected := []byte{1, 0xAA, 0xBB, 4, 5, 0, 0xCC, 0xCC, 0xCC}
+	if err != nil || !bytes.Equal(actual, expected) {
+		t.Errorf("got: %v %v,but expected: %v", actual, err, expected)
+	}
+	if rom[1] != 2 {
+		t.Errorf("got: %v,but expected: the ROM left untouched", rom)
+	}
+
+	truncated, err := IPSPatch(append(patch, 0, 0, 4)).Apply(rom)
+	if err != nil || !bytes.Equal(truncated, expected[:4]) {
+		t.Errorf("got: %v %v,but expected: %v", truncated, err, expected[:4])
+	}
+	if _, err := IPSPatch(patch[:len(patch)-3]).Apply(rom); err == nil {
+		t.Errorf("got: no error,but expected: a truncated patch")
+	}
+}
+
+func bpsNumber(n int) []byte {
+	var b []byte
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(b, 0x80|x)
+		}
+		b = append(b, x)
+		n--
+	}
+}
+
+func bpsPatch(source, target []byte, actions ...[]byte) []byte {
+	p := []byte("BPS1")
+	p = append(p, bpsNumber(len(source))...)
+	p = append(p, bpsNumber(len(target))...)
+	p = append(p, bpsNumber(0)...)
+	for _, action := range actions {
+		p = append(p, action...)
+	}
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(source))
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(p))
+}
+
+func TestBPSPatch(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	target := []byte("XYCDABXYC")
+	patch := bpsPatch(source, target,
+		append(bpsNumber(1<<2|1), 'X', 'Y'),        // target read of 2 bytes
+		bpsNumber(1<<2|0),                          // source read of 2 bytes
+		append(bpsNumber(1<<2|2), bpsNumber(0)...), // source copy of 2 bytes from 0
+		append(bpsNumber(2<<2|3), bpsNumber(0)...), // target copy of 3 bytes from 0
+	)
+	actual, err := BPSPatch(patch).Apply(source)
+	if err != nil || !bytes.Equal(actual, target) {
+		t.Errorf("got: %q %v,but expected: %q", actual, err, target)
+	}
+
+	if _, err := BPSPatch(patch).Apply([]byte("ABCDEFGX")); err == nil {
+		t.Errorf("got: no error,but expected: a CRC32 error for another ROM")
+	}
+	corrupted := append([]byte(nil), patch...)
+	corrupted[len("BPS1")+4] ^= 1
+	if _, err := BPSPatch(corrupted).Apply(source); err == nil {
+		t.Errorf("got: no error,but expected: a CRC32 error for a corrupted patch")
+	}
+
+	for _, size := range [][]byte{
+		{0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0xFF},       // overflowing an int
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}, // passing 63 bits
+	} {
+		huge := append([]byte("BPS1"), bpsNumber(len(source))...)
+		huge = append(huge, size...)
+		huge = append(huge, bpsNumber(0)...)
+		huge = binary.LittleEndian.AppendUint32(huge, crc32.ChecksumIEEE(source))
+		huge = binary.LittleEndian.AppendUint32(huge, crc32.ChecksumIEEE(target))
+		huge = binary.LittleEndian.AppendUint32(huge, crc32.ChecksumIEEE(huge))
+		if _, err := BPSPatch(huge).Apply(source); err != errBPSNumber {
+			t.Errorf("got: %v,but expected: %v for the target size % X", err, errBPSNumber, size)
+		}
+	}
+}
+
+func TestEmulator_LoadPatched(t *testing.T) {
+	emu := NewEmulator(NewFonts())
+	patch := append([]byte("PATCH"), 0, 0, 1, 0, 1, 0x04)
+	patch = append(patch, []byte("EOF")...)
+	if err := emu.Load([]byte{0x12, 0x00}, IPSPatch(patch)); err != nil {
+		t.Fatal(err)
+	}
+	if emu.Memory[0x200] != 0x12 || emu.Memory[0x201] != 0x04 {
+		t.Errorf("got: %02X%02X,but expected: 1204", emu.Memory[0x200], emu.Memory[0x201])
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+// Persistence is a display filter keeping the pixels lit for a while after
+// they are turned off, like the phosphor of a CRT, against the flicker of
+// the sprites erased and drawn again every frame. It only changes what is
+// drawn, never Gfx, so collisions are unaffected.
+type Persistence struct {
+	Hold  int     // frames a pixel stays fully lit after it is turned off
+	Decay float64 // part of its brightness a pixel keeps every frame after that, at least 0 and below 1
+
+	brightness [2048]float64
+	lit        [2048]uint8 // last value of the lit pixels
+	off        [2048]int   // frames since the pixel was turned off
+}
+
+// Update follows the display after a frame. It reports whether some pixels
+// are fading, so that the display has to be drawn again.
+func (p *Persistence) Update(gfx *[2048]uint8) bool {
+	fading := false
+	for i, pixel := range gfx {
+		if pixel != 0 {
+			p.brightness[i], p.lit[i], p.off[i] = 1, pixel, 0
+			continue
+		}
+		if p.brightness[i] == 0 {
+			continue
+		}
+		if p.off[i]++; p.off[i] > p.Hold {
+			if p.brightness[i] *= p.Decay; p.brightness[i] < 1.0/255 {
+				p.brightness[i] = 0
+			}
+		}
+		fading = true
+	}
+	return fading
+}
+
+// Color returns the color to draw the i-th pixel with
+func (p *Persistence) Color(i int, palette Palette) Color {
+	background := palette.Color(0)
+	if p.brightness[i] == 0 {
+		return background
+	}
+	lit := palette.Color(p.lit[i])
+	mix := func(from, to uint8) uint8 {
+		return uint8(float64(from) + (float64(to)-float64(from))*p.brightness[i] + 0.5)
+	}
+	return Color{mix(background.R, lit.R), mix(background.G, lit.G), mix(background.B, lit.B)}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestPersistence(t *testing.T) {
+	p := &Persistence{Hold: 1, Decay: 0.5}
+	var gfx [2048]uint8
+	gfx[0] = 1
+	if p.Update(&gfx) {
+		t.Errorf("got: fading,but expected: no pixel fading")
+	}
+	gfx[0] = 0
+	expected := []float64{1, 0.5, 0.25}
+	for _, brightness := range expected {
+		if !p.Update(&gfx) || p.brightness[0] != brightness {
+			t.Errorf("got: %v,but expected: %v", p.brightness[0], brightness)
+		}
+	}
+	if gfx[0] != 0 {
+		t.Errorf("got: %v,but expected: Gfx left untouched", gfx[0])
+	}
+
+	palette := Palette{{0, 0, 0}, {200, 100, 0}}
+	if actual := p.Color(0, palette); actual != (Color{50, 25, 0}) {
+		t.Errorf("got: %v,but expected: a quarter of the lit color", actual)
+	}
+	for i := 0; i < 8; i++ {
+		p.Update(&gfx)
+	}
+	if p.Update(&gfx) || p.Color(0, palette) != palette[0] {
+		t.Errorf("got: %v,but expected: the pixel off", p.brightness[0])
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// InputPolicy returns the keys held during a frame of a job
+type InputPolicy func(e *Emulator, frame int) [16]bool
+
+// Job is a run of a ROM for a budget of frames
+type Job struct {
+	ROM    []byte
+	Frames int
+	Seed   int64
+	Speed  int         // instructions per frame, the ROM's when zero
+	Policy InputPolicy // no key is pressed when nil
+}
+
+// Result is the outcome of a Job
+type Result struct {
+	Job      int       // index of the job
+	Frames   int       // frames actually run
+	Emulator *Emulator // final state of the emulator
+	Err      error     // error of the program, or of the context when canceled
+}
+
+// SetKeys sets the state of the 16 keys
+func (e *Emulator) SetKeys(keys [16]bool) {
+	e.keys = keys
+}
+
+// RunJob runs a job on a fresh emulator, stopping early when ctx is done.
+// A panic of the job is returned as its error rather than taking down the
+// other jobs.
+func RunJob(ctx context.Context, job Job) (r Result) {
+	emu := NewEmulator(NewFonts())
+	emu.Seed(job.Seed)
+	r = Result{Emulator: emu}
+	defer func() {
+		if p := recover(); p != nil {
+			r.Err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	if r.Err = emu.Load(job.ROM); r.Err != nil {
+		return r
+	}
+	if job.Speed > 0 {
+		emu.Speed = job.Speed
+	}
+	for ; r.Frames < job.Frames; r.Frames++ {
+		if r.Err = ctx.Err(); r.Err != nil {
+			return r
+		}
+		if job.Policy != nil {
+			emu.SetKeys(job.Policy(emu, r.Frames))
+		}
+		if r.Err = emu.Frame(); r.Err != nil {
+			return r
+		}
+	}
+	return r
+}
+
+// RunPool runs jobs on independent emulators across workers goroutines and
+// returns their results in the order of jobs. Canceling ctx stops the jobs
+// in progress and skips the others, whose results hold the context error.
+func RunPool(ctx context.Context, workers int, jobs []Job) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = RunJob(ctx, jobs[i])
+				results[i].Job = i
+			}
+		}()
+	}
+
+	i := 0
+	for ; i < len(jobs); i++ {
+		select {
+		case indexes <- i:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	close(indexes)
+	wg.Wait()
+	for ; i < len(jobs); i++ {
+		results[i] = Result{Job: i, Err: ctx.Err()}
+	}
+	return results
+}
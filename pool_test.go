@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRunPool(t *testing.T) {
+	rom, err := os.ReadFile("roms/BRIX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hold the key moving the paddle to the right every other second
+	right := func(e *Emulator, frame int) [16]bool {
+		var keys [16]bool
+		keys[6] = frame/60%2 == 0
+		return keys
+	}
+	var jobs []Job
+	for i := 0; i < 8; i++ {
+		jobs = append(jobs, Job{ROM: rom, Frames: 3000, Seed: int64(i % 2), Speed: 10, Policy: right})
+	}
+	results := RunPool(context.Background(), 4, jobs)
+	for i, r := range results {
+		if r.Err != nil || r.Job != i || r.Frames != 3000 {
+			t.Fatalf("got: job %v, %v frames, error %v,but expected: job %v, 3000 frames", r.Job, r.Frames, r.Err, i)
+		}
+	}
+	// runs with the same seed and input are identical
+	for i := 2; i < len(results); i++ {
+		if !sameState(results[i].Emulator, results[i%2].Emulator) {
+			t.Errorf("got: job %v differs from job %v,but expected them to be identical", i, i%2)
+		}
+	}
+}
+
+func TestRunPool_Cancel(t *testing.T) {
+	rom, err := os.ReadFile("roms/BRIX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := RunPool(ctx, 2, []Job{{ROM: rom, Frames: 100}, {ROM: rom, Frames: 100}, {ROM: rom, Frames: 100}})
+	for _, r := range results {
+		if r.Err != context.Canceled {
+			t.Errorf("got: %v,but expected: %v", r.Err, context.Canceled)
+		}
+	}
+}
+
+func TestRunJob_MalformedROM(t *testing.T) {
+	tests := []struct {
+		name     string
+		rom      []byte
+		expected string
+	}{
+		{"return with an empty stack", []byte{0x00, 0xEE}, "stack underflow by opcode 0x00ee at 0x200"},
+		{"jump past the memory", []byte{0x60, 0xFF, 0xBF, 0xFF}, "program counter 0x10fe outside the memory"},
+		{"endless recursion", []byte{0x22, 0x00}, "stack overflow by opcode 0x2200 at 0x200"},
+	}
+	for _, test := range tests {
+		r := RunJob(context.Background(), Job{ROM: test.rom, Frames: 100})
+		if r.Err == nil || r.Err.Error() != test.expected {
+			t.Errorf("got: %v for %s,but expected: %s", r.Err, test.name, test.expected)
+		}
+	}
+	policy := func(e *Emulator, frame int) [16]bool {
+		panic("policy bug")
+	}
+	if r := RunJob(context.Background(), Job{ROM: []byte{0x12, 0x00}, Frames: 10, Policy: policy}); r.Err == nil {
+		t.Errorf("got: no error,but expected: the panic of the policy")
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// call is a subroutine call on the profiler's shadow stack
+type call struct {
+	site  uint16 // address of the 2NNN instruction
+	entry uint16 // address of the subroutine
+}
+
+// Profiler counts executed instructions per address, opcode family and subroutine.
+// It is a Tracer, so it is enabled with AddTracer.
+type Profiler struct {
+	pcs       [4096]uint64
+	families  map[string]uint64
+	self      map[uint16]uint64 // per subroutine, excluding callees
+	total     map[uint16]uint64 // per subroutine, including callees
+	calls     map[uint16]uint64 // number of calls per subroutine
+	stacks    map[string]uint64 // per call stack, for pprof
+	stack     []call
+	count     uint64
+	startTime time.Time
+}
+
+// NewProfiler creates an empty Profiler
+func NewProfiler() *Profiler {
+	return &Profiler{
+		families:  map[string]uint64{},
+		self:      map[uint16]uint64{},
+		total:     map[uint16]uint64{},
+		calls:     map[uint16]uint64{},
+		stacks:    map[string]uint64{},
+		startTime: time.Now(),
+	}
+}
+
+// Family returns the opcode family of an instruction, such as 8XY4 or FX33
+func Family(opcode uint16) string {
+	switch opcode & 0xF000 {
+	case 0x0000:
+		if opcode == 0x00E0 || opcode == 0x00EE {
+			return fmt.Sprintf("%04X", opcode)
+		}
+		return "0NNN"
+	case 0x1000, 0x2000, 0xA000, 0xB000:
+		return fmt.Sprintf("%XNNN", opcode>>12)
+	case 0x3000, 0x4000, 0x6000, 0x7000, 0xC000:
+		return fmt.Sprintf("%XXNN", opcode>>12)
+	case 0x5000, 0x9000:
+		return fmt.Sprintf("%XXY0", opcode>>12)
+	case 0x8000:
+		return fmt.Sprintf("8XY%X", opcode&0x000F)
+	case 0xD000:
+		return "DXYN"
+	default:
+		return fmt.Sprintf("%XX%02X", opcode>>12, opcode&0x00FF)
+	}
+}
+
+// current returns the entry address of the running subroutine
+func (p *Profiler) current() uint16 {
+	if len(p.stack) == 0 {
+		return 0x200
+	}
+	return p.stack[len(p.stack)-1].entry
+}
+
+// Trace counts a record
+func (p *Profiler) Trace(r *TraceRecord) {
+	p.count++
+	p.pcs[r.Pc&0x0FFF]++
+	p.families[Family(r.Opcode)]++
+	p.self[p.current()]++
+	p.total[0x200]++
+	for i, c := range p.stack {
+		// count recursive subroutines once
+		counted := c.entry == 0x200
+		for _, outer := range p.stack[:i] {
+			counted = counted || outer.entry == c.entry
+		}
+		if !counted {
+			p.total[c.entry]++
+		}
+	}
+	p.stacks[p.stackKey(r.Pc)]++
+
+	switch {
+	case r.Opcode&0xF000 == 0x2000:
+		p.stack = append(p.stack, call{site: r.Pc, entry: r.Next})
+		p.calls[r.Next]++
+	case r.Opcode == 0x00EE && len(p.stack) > 0:
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// stackKey encodes pc and the call sites above it, innermost first
+func (p *Profiler) stackKey(pc uint16) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%03X", pc)
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, ",%03X", p.stack[i].site)
+	}
+	return b.String()
+}
+
+func subroutineName(entry uint16) string {
+	if entry == 0x200 {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", entry)
+}
+
+type profileEntry struct {
+	name  string
+	count uint64
+}
+
+func sortedEntries(entries []profileEntry) []profileEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
+
+func (p *Profiler) percent(n uint64) float64 {
+	if p.count == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(p.count)
+}
+
+// WriteReport writes the hotspots sorted by count followed by the annotated
+// disassembly of every executed address of e's memory
+func (p *Profiler) WriteReport(w io.Writer, e *Emulator, top int) {
+	fmt.Fprintf(w, "%d instructions executed\n\n", p.count)
+
+	var pcs []profileEntry
+	for pc, n := range p.pcs {
+		if n > 0 {
+			pcs = append(pcs, profileEntry{fmt.Sprintf("%03X", pc), n})
+		}
+	}
+	pcs = sortedEntries(pcs)
+	if top > 0 && len(pcs) > top {
+		pcs = pcs[:top]
+	}
+	fmt.Fprintln(w, "hot addresses:")
+	for _, en := range pcs {
+		var pc uint16
+		fmt.Sscanf(en.name, "%X", &pc)
+		fmt.Fprintf(w, "%12d %6.2f%%  %s  %s\n", en.count, p.percent(en.count), en.name, Disassemble(e.opcodeAt(pc)))
+	}
+
+	var families []profileEntry
+	for f, n := range p.families {
+		families = append(families, profileEntry{f, n})
+	}
+	fmt.Fprintln(w, "\nopcode families:")
+	for _, en := range sortedEntries(families) {
+		fmt.Fprintf(w, "%12d %6.2f%%  %s\n", en.count, p.percent(en.count), en.name)
+	}
+
+	var subs []profileEntry
+	for entry, n := range p.total {
+		subs = append(subs, profileEntry{subroutineName(entry), n})
+	}
+	fmt.Fprintln(w, "\nsubroutines:          total            self      calls")
+	for _, en := range sortedEntries(subs) {
+		var entry uint16 = 0x200
+		fmt.Sscanf(en.name, "sub_%X", &entry)
+		fmt.Fprintf(w, "%-12s %12d %6.2f%% %12d %6.2f%% %8d\n", en.name,
+			en.count, p.percent(en.count), p.self[entry], p.percent(p.self[entry]), p.calls[entry])
+	}
+
+	if loops := p.delayLoops(e); len(loops) > 0 {
+		fmt.Fprintln(w, "\nbusy-wait loops on the delay timer:")
+		for _, l := range loops {
+			fmt.Fprintln(w, l)
+		}
+	}
+
+	fmt.Fprintln(w, "\nannotated disassembly:")
+	last := -1
+	for pc, n := range p.pcs {
+		if n == 0 {
+			continue
+		}
+		if last >= 0 && pc != last+2 {
+			fmt.Fprintln(w, "         ...")
+		}
+		opcode := e.opcodeAt(uint16(pc))
+		fmt.Fprintf(w, "%12d  %03X  %04X  %s\n", n, pc, opcode, Disassemble(opcode))
+		last = pc
+	}
+}
+
+// delayLoops finds backward jumps over a block reading the delay timer
+func (p *Profiler) delayLoops(e *Emulator) []string {
+	var loops []string
+	for pc, n := range p.pcs {
+		opcode := e.opcodeAt(uint16(pc))
+		if n == 0 || opcode&0xF000 != 0x1000 || int(opcode&0x0FFF) > pc {
+			continue
+		}
+		for a := int(opcode & 0x0FFF); a < pc; a += 2 {
+			if p.pcs[a] > 0 && e.opcodeAt(uint16(a))&0xF0FF == 0xF007 {
+				loops = append(loops, fmt.Sprintf("%12d iterations  %03X-%03X", n, opcode&0x0FFF, pc))
+				break
+			}
+		}
+	}
+	return loops
+}
+
+// opcodeAt reads the instruction at addr without touching Pc
+func (e *Emulator) opcodeAt(addr uint16) uint16 {
+	addr &= 0x0FFF
+	return uint16(e.Memory[addr])<<8 | uint16(e.Memory[(addr+1)&0x0FFF])
+}
+
+// WritePprof writes the call stack samples as a gzipped pprof profile,
+// which can be read with "go tool pprof"
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var b protoBuffer
+	strs := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = int64(len(table))
+		table = append(table, s)
+		return strs[s]
+	}
+
+	valueType := func(typ, unit string) []byte {
+		var v protoBuffer
+		v.int64(1, str(typ))
+		v.int64(2, str(unit))
+		return v.bytes()
+	}
+	b.message(1, valueType("instructions", "count"))
+
+	// every address seen in a stack is a location whose function is the
+	// subroutine it belongs to
+	locs := map[uint16]bool{}
+	for _, s := range p.sortedStacks() {
+		var sample protoBuffer
+		var ids []uint64
+		for _, pc := range parseStackKey(s) {
+			locs[pc] = true
+			ids = append(ids, uint64(pc)+1)
+		}
+		sample.packed(1, ids)
+		sample.packed(2, []uint64{p.stacks[s]})
+		b.message(2, sample.bytes())
+	}
+
+	funcs := map[uint16]bool{}
+	for pc := uint16(0); pc < 0x1000; pc++ {
+		if !locs[pc] {
+			continue
+		}
+		entry := p.owner(pc)
+		funcs[entry] = true
+		var line protoBuffer
+		line.uint64(1, uint64(entry)+1)
+		line.int64(2, int64(pc))
+		var loc protoBuffer
+		loc.uint64(1, uint64(pc)+1)
+		loc.uint64(3, uint64(pc))
+		loc.message(4, line.bytes())
+		b.message(4, loc.bytes())
+	}
+	var ids []int
+	for entry := range funcs {
+		ids = append(ids, int(entry))
+	}
+	sort.Ints(ids)
+	for _, entry := range ids {
+		var fn protoBuffer
+		fn.uint64(1, uint64(entry)+1)
+		fn.int64(2, str(subroutineName(uint16(entry))))
+		fn.int64(3, str(subroutineName(uint16(entry))))
+		fn.int64(4, str("rom"))
+		fn.int64(5, int64(entry))
+		b.message(5, fn.bytes())
+	}
+
+	// the string table is complete only once everything else has been encoded
+	var tail protoBuffer
+	tail.int64(9, p.startTime.UnixNano())
+	tail.int64(10, int64(time.Since(p.startTime)))
+	tail.message(11, valueType("instructions", "count"))
+	tail.int64(12, 1)
+	for _, s := range table {
+		b.string(6, s)
+	}
+	b.buf.Write(tail.bytes())
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (p *Profiler) sortedStacks() []string {
+	var keys []string
+	for k := range p.stacks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parseStackKey(key string) []uint16 {
+	var pcs []uint16
+	for _, s := range strings.Split(key, ",") {
+		var pc uint16
+		fmt.Sscanf(s, "%X", &pc)
+		pcs = append(pcs, pc)
+	}
+	return pcs
+}
+
+// owner returns the nearest subroutine entry at or below pc
+func (p *Profiler) owner(pc uint16) uint16 {
+	owner := uint16(0x200)
+	for entry := range p.calls {
+		if entry <= pc && entry > owner {
+			owner = entry
+		}
+	}
+	return owner
+}
+
+// WriteProfileFiles writes the text report and, when pprofPath is set, the pprof profile
+func (p *Profiler) WriteProfileFiles(reportPath string, pprofPath string, e *Emulator) error {
+	if reportPath != "" {
+		file, err := os.Create(reportPath)
+		if err != nil {
+			return err
+		}
+		p.WriteReport(file, e, 50)
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	if pprofPath != "" {
+		file, err := os.Create(pprofPath)
+		if err != nil {
+			return err
+		}
+		if err := p.WritePprof(file); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return nil
+}
+
+// protoBuffer is the bare minimum of protobuf encoding needed for pprof
+type protoBuffer struct {
+	buf bytes.Buffer
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	b.buf.WriteByte(byte(v))
+}
+
+func (b *protoBuffer) uint64(field int, v uint64) {
+	b.varint(uint64(field) << 3)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+func (b *protoBuffer) message(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.buf.Write(data)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.message(field, []byte(s))
+}
+
+func (b *protoBuffer) packed(field int, vs []uint64) {
+	var p protoBuffer
+	for _, v := range vs {
+		p.varint(v)
+	}
+	b.message(field, p.bytes())
+}
+
+func (b *protoBuffer) bytes() []byte {
+	return b.buf.Bytes()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProfiler_Subroutines(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	// 200: CALL 0x206; 202: JP 0x200; 206: ADD V0, 1; 208: RET
+	copy(emu.Memory[0x200:], []byte{0x22, 0x06, 0x12, 0x00, 0x00, 0x00, 0x70, 0x01, 0x00, 0xEE})
+	profiler := NewProfiler()
+	emu.AddTracer(profiler)
+	for i := 0; i < 8; i++ {
+		emu.Step()
+	}
+	if actual := profiler.calls[0x206]; actual != 2 {
+		t.Errorf("got: %v calls,but expected: 2", actual)
+	}
+	if actual := profiler.self[0x206]; actual != 4 {
+		t.Errorf("got: %v instructions in sub_206,but expected: 4", actual)
+	}
+	if actual := profiler.families["7XNN"]; actual != 2 {
+		t.Errorf("got: %v 7XNN,but expected: 2", actual)
+	}
+
+	var report bytes.Buffer
+	profiler.WriteReport(&report, emu, 10)
+	if !strings.Contains(report.String(), "sub_206") {
+		t.Errorf("report does not mention sub_206:\n%s", report.String())
+	}
+	var pprof bytes.Buffer
+	if err := profiler.WritePprof(&pprof); err != nil {
+		t.Error(err)
+	}
+}
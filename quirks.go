@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quirks are the behaviours on which the CHIP-8 interpreters disagree. The
+// zero value is the historical behaviour of this emulator.
+type Quirks struct {
+	ShiftVY     bool // 8XY6 and 8XYE shift VY into VX instead of shifting VX
+	IncrementI  bool // FX55 and FX65 leave I past the last register
+	ResetVF     bool // 8XY1, 8XY2 and 8XY3 reset VF
+	ClipSprites bool // DXYN clips sprites at the edges instead of wrapping them
+	JumpVX      bool // BNNN jumps to NNN plus VX instead of V0, X being the first digit of NNN
+}
+
+// Quirks profiles by name
+var QuirkProfiles = map[string]Quirks{
+	"default": {},
+	"vip":     {ShiftVY: true, IncrementI: true, ResetVF: true, ClipSprites: true},
+	"schip":   {ClipSprites: true, JumpVX: true},
+	"xochip":  {ShiftVY: true, IncrementI: true},
+}
+
+// ParseQuirks returns a quirks profile by name
+func ParseQuirks(name string) (Quirks, error) {
+	q, ok := QuirkProfiles[name]
+	if !ok {
+		var names []string
+		for name := range QuirkProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Quirks{}, fmt.Errorf("unknown quirks profile %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return q, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestEmulator_Quirks(t *testing.T) {
+	fonts := NewFonts()
+	for _, test := range []struct {
+		profile string
+		opcode  uint16
+		setup   func(e *Emulator)
+		check   func(e *Emulator) bool
+	}{
+		{"default", 0x8126, func(e *Emulator) { e.V[1], e.V[2] = 0x04, 0x03 }, func(e *Emulator) bool { return e.V[1] == 0x02 && e.V[0xF] == 0 }},
+		{"vip", 0x8126, func(e *Emulator) { e.V[1], e.V[2] = 0x04, 0x03 }, func(e *Emulator) bool { return e.V[1] == 0x01 && e.V[0xF] == 1 }},
+		{"vip", 0x812E, func(e *Emulator) { e.V[1], e.V[2] = 0x01, 0x81 }, func(e *Emulator) bool { return e.V[1] == 0x02 && e.V[0xF] == 1 }},
+		{"default", 0xF265, func(e *Emulator) { e.I = 0x300 }, func(e *Emulator) bool { return e.I == 0x300 }},
+		{"vip", 0xF265, func(e *Emulator) { e.I = 0x300 }, func(e *Emulator) bool { return e.I == 0x303 }},
+		{"xochip", 0xF255, func(e *Emulator) { e.I = 0x300 }, func(e *Emulator) bool { return e.I == 0x303 }},
+		{"default", 0x8121, func(e *Emulator) { e.V[0xF] = 1 }, func(e *Emulator) bool { return e.V[0xF] == 1 }},
+		{"vip", 0x8121, func(e *Emulator) { e.V[0xF] = 1 }, func(e *Emulator) bool { return e.V[0xF] == 0 }},
+		{"default", 0xB210, func(e *Emulator) { e.V[0], e.V[2] = 1, 2 }, func(e *Emulator) bool { return e.Pc == 0x211 }},
+		{"schip", 0xB210, func(e *Emulator) { e.V[0], e.V[2] = 1, 2 }, func(e *Emulator) bool { return e.Pc == 0x212 }},
+		// an 8 pixels wide line drawn at x=60 wraps or is clipped
+		{"default", 0xD011, func(e *Emulator) { e.V[0], e.I = 60, 0x300; e.Memory[0x300] = 0xFF }, func(e *Emulator) bool { return e.Gfx[0] == 1 && e.Gfx[63] == 1 }},
+		{"vip", 0xD011, func(e *Emulator) { e.V[0], e.I = 60, 0x300; e.Memory[0x300] = 0xFF }, func(e *Emulator) bool { return e.Gfx[0] == 0 && e.Gfx[63] == 1 }},
+	} {
+		emu := NewEmulator(fonts)
+		emu.Quirks = QuirkProfiles[test.profile]
+		test.setup(emu)
+		emu.Exec(test.opcode)
+		if !test.check(emu) {
+			t.Errorf("got: V %v I 0x%x pc 0x%x after %04X,but expected the %s behaviour", emu.V, emu.I, emu.Pc, test.opcode, test.profile)
+		}
+	}
+
+	if _, err := ParseQuirks("cosmac"); err == nil {
+		t.Errorf("got: no error,but expected: an unknown profile error")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/veandco/go-sdl2/sdl"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Platforms of the ROMs
+const (
+	PlatformChip8  = "CHIP-8"
+	PlatformSchip  = "SCHIP"
+	PlatformXOChip = "XO-CHIP"
+)
+
+// platformQuirks are the quirks profiles of the platforms, for the ROMs
+// missing from the database
+var platformQuirks = map[string]string{
+	PlatformChip8:  "default",
+	PlatformSchip:  "schip",
+	PlatformXOChip: "xochip",
+}
+
+// RomInfo describes a ROM and the settings it plays best with
+type RomInfo struct {
+	SHA1     string            `json:"sha1"`
+	Title    string            `json:"title"`
+	Author   string            `json:"author,omitempty"`
+	Year     int               `json:"year,omitempty"`
+	Platform string            `json:"platform"`
+	Quirks   string            `json:"quirks,omitempty"`  // quirks profile, the platform's when empty
+	Speed    int               `json:"speed,omitempty"`   // instructions per frame
+	Keys     map[string]string `json:"keys,omitempty"`    // extra keys, such as "Left": "4"
+	Palette  Palette           `json:"palette,omitempty"` // the default palette when empty
+	Known    bool              `json:"-"`                 // found in the database
+	Detected *Detection        `json:"-"`                 // the guess for an unknown ROM
+}
+
+//go:embed romdb.json
+var romDBFile []byte
+
+var romDB struct {
+	once sync.Once
+	roms map[string]*RomInfo
+}
+
+// RomDB returns the built-in database of ROMs by SHA-1
+func RomDB() map[string]*RomInfo {
+	romDB.once.Do(func() {
+		var roms []*RomInfo
+		if err := json.Unmarshal(romDBFile, &roms); err != nil {
+			panic(fmt.Sprintf("romdb.json: %v", err))
+		}
+		romDB.roms = map[string]*RomInfo{}
+		for _, info := range roms {
+			info.Known = true
+			romDB.roms[strings.ToLower(info.SHA1)] = info
+		}
+	})
+	return romDB.roms
+}
+
+// IdentifyRom looks a ROM up in the database. An unknown ROM gets the
+// defaults with the platform and quirks of DetectPlatform.
+func IdentifyRom(rom []byte) *RomInfo {
+	sha1 := RomHash(rom)
+	if info, ok := RomDB()[sha1]; ok {
+		return info
+	}
+	d := DetectPlatform(rom)
+	return &RomInfo{SHA1: sha1, Platform: d.Platform, Quirks: d.Quirks, Detected: &d}
+}
+
+// QuirksProfile returns the name of the quirks profile of the ROM
+func (info *RomInfo) QuirksProfile() string {
+	if info.Quirks != "" {
+		return info.Quirks
+	}
+	return platformQuirks[info.Platform]
+}
+
+// configure applies the settings of a ROM to the emulator
+func (e *Emulator) configure(info *RomInfo) {
+	e.Rom = info
+	e.Quirks = QuirkProfiles[info.QuirksProfile()]
+	if info.Speed > 0 {
+		e.Speed = info.Speed
+	}
+	e.keyMap = NewKeyMap()
+	for name, key := range info.Keys {
+		k, err := strconv.ParseUint(key, 16, 4)
+		scancode := sdl.GetScancodeFromName(name)
+		if err == nil && scancode != sdl.SCANCODE_UNKNOWN {
+			e.keyMap[int(scancode)] = byte(k)
+		}
+	}
+	e.Palette = DefaultPalette
+	if len(info.Palette) > 0 {
+		e.Palette = info.Palette
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRomDB(t *testing.T) {
+	for sha1, info := range RomDB() {
+		if _, ok := QuirkProfiles[info.QuirksProfile()]; !ok {
+			t.Errorf("got: quirks %q for %s,but expected: a profile", info.QuirksProfile(), sha1)
+		}
+		if info.Title == "" || info.Platform == "" {
+			t.Errorf("got: %+v,but expected: a title and a platform", info)
+		}
+	}
+	for _, path := range romPaths(t) {
+		rom, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info := IdentifyRom(rom); !info.Known {
+			t.Errorf("got: %v unknown,but expected: in the database", path)
+		}
+	}
+}
+
+func TestEmulator_LoadConfigures(t *testing.T) {
+	rom, err := os.ReadFile("roms/WIPEOFF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := NewEmulator(NewFonts())
+	if err := emu.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	if emu.Rom.Title != "Wipe Off" || emu.Quirks != QuirkProfiles["vip"] || emu.Speed != 10 {
+		t.Errorf("got: %+v %+v,but expected: Wipe Off with the vip quirks", emu.Rom, emu.Quirks)
+	}
+
+	emu = NewEmulator(NewFonts())
+	if err := emu.Load([]byte{0x00, 0xE0, 0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if emu.Rom.Known || emu.Rom.Platform != PlatformChip8 || emu.Quirks != (Quirks{}) || emu.Speed != 1 || emu.Palette.String() != DefaultPalette.String() {
+		t.Errorf("got: %+v,but expected: an unknown CHIP-8 ROM with the defaults", emu.Rom)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// MemoryEventKind tells what kind of self-modifying code was detected
+type MemoryEventKind int
+
+const (
+	// CodeOverwritten is raised when a byte which has been executed is written
+	CodeOverwritten MemoryEventKind = iota
+	// WrittenCodeExecuted is raised when a byte written by the program is executed
+	WrittenCodeExecuted
+)
+
+// MemoryEvent reports self-modifying code
+type MemoryEvent struct {
+	Kind  MemoryEventKind
+	Cycle uint64 // cycle of the instruction causing the event
+	Pc    uint16 // address of the instruction causing the event
+	Addr  uint16 // address of the byte written or executed
+}
+
+func (ev MemoryEvent) String() string {
+	if ev.Kind == CodeOverwritten {
+		return fmt.Sprintf("code at %03X overwritten", ev.Addr)
+	}
+	return fmt.Sprintf("written memory at %03X executed", ev.Addr)
+}
+
+func (e *Emulator) memoryEvent(kind MemoryEventKind, addr uint16) {
+	ev := MemoryEvent{Kind: kind, Cycle: e.Cycle, Pc: e.Pc, Addr: addr}
+	e.events = append(e.events, ev)
+	if e.OnMemoryEvent != nil {
+		e.OnMemoryEvent(ev)
+	}
+}
+
+// markExecuted records the fetch of the instruction at pc
+func (e *Emulator) markExecuted(pc uint16) {
+	for _, addr := range [2]uint16{pc & 0x0FFF, (pc + 1) & 0x0FFF} {
+		if e.fresh[addr] {
+			e.fresh[addr] = false
+			e.memoryEvent(WrittenCodeExecuted, addr)
+		}
+		e.executed[addr] = true
+	}
+}
+
+// Executed reports whether the byte at addr has been executed
+func (e *Emulator) Executed(addr uint16) bool {
+	return e.executed[addr&0x0FFF]
+}
+
+// Written reports whether the byte at addr has been written by the program
+func (e *Emulator) Written(addr uint16) bool {
+	return e.written[addr&0x0FFF]
+}
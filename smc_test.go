@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestEmulator_SelfModifyingCode(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	// 200: LD V0, 0x12; 202: LD V1, 0x08; 204: LD I, 0x200; 206: LD [I], V1; 208: JP 0x200
+	copy(emu.Memory[0x200:], []byte{0x60, 0x12, 0x61, 0x08, 0xA2, 0x00, 0xF1, 0x55, 0x12, 0x00})
+	var events []MemoryEvent
+	emu.OnMemoryEvent = func(ev MemoryEvent) {
+		events = append(events, ev)
+	}
+	for i := 0; i < 4; i++ {
+		emu.Step()
+	}
+	expected := []MemoryEvent{{CodeOverwritten, 3, 0x206, 0x200}, {CodeOverwritten, 3, 0x206, 0x201}}
+	if len(events) != 2 || events[0] != expected[0] || events[1] != expected[1] {
+		t.Errorf("got: %v,but expected: %v", events, expected)
+	}
+
+	// JP 0x200 then the JP 0x208 which was written
+	emu.Step()
+	emu.Step()
+	if emu.Pc != 0x208 {
+		t.Errorf("got: 0x%x,but expected: 0x208", emu.Pc)
+	}
+	expected = []MemoryEvent{{WrittenCodeExecuted, 5, 0x200, 0x200}, {WrittenCodeExecuted, 5, 0x200, 0x201}}
+	if len(events) != 4 || events[2] != expected[0] || events[3] != expected[1] {
+		t.Errorf("got: %v,but expected: %v", events[2:], expected)
+	}
+	if !emu.Written(0x201) || emu.Written(0x202) {
+		t.Errorf("got: written 0x201 %v 0x202 %v,but expected: true false", emu.Written(0x201), emu.Written(0x202))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RegChange is a V register modified by an instruction
+type RegChange struct {
+	Reg uint8 `json:"reg"`
+	Old uint8 `json:"old"`
+	New uint8 `json:"new"`
+}
+
+// MemWrite is a memory byte written by an instruction
+type MemWrite struct {
+	Addr uint16 `json:"addr"`
+	Old  uint8  `json:"old"`
+	New  uint8  `json:"new"`
+}
+
+// TraceRecord describes one executed instruction
+type TraceRecord struct {
+	Cycle  uint64        // value of Cycle before the instruction
+	Pc     uint16        // address of the instruction
+	Opcode uint16        // the instruction itself
+	Regs   []RegChange   // V registers it changed
+	Writes []MemWrite    // memory it wrote
+	Events []MemoryEvent // self-modifying code it caused
+	I      uint16        // index register after the instruction
+	Sp     uint16        // stack pointer after the instruction
+	Next   uint16        // program counter after the instruction
+}
+
+// Tracer receives a record for every instruction executed by Step
+type Tracer interface {
+	Trace(r *TraceRecord)
+}
+
+// AddTracer registers a tracer on the emulator.
+// Tracing is off as long as no tracer is registered.
+func (e *Emulator) AddTracer(t Tracer) {
+	e.tracers = append(e.tracers, t)
+}
+
+// RemoveTracer unregisters a tracer
+func (e *Emulator) RemoveTracer(t Tracer) {
+	for i, tracer := range e.tracers {
+		if tracer == t {
+			e.tracers = append(e.tracers[:i:i], e.tracers[i+1:]...)
+			return
+		}
+	}
+}
+
+// TraceFilter selects which instructions are traced
+type TraceFilter struct {
+	PcLow   uint16
+	PcHigh  uint16
+	Classes [16]bool // opcode classes by their highest nibble
+}
+
+// NewTraceFilter creates a filter which lets everything through
+func NewTraceFilter() TraceFilter {
+	f := TraceFilter{PcLow: 0x000, PcHigh: 0xFFF}
+	for i := range f.Classes {
+		f.Classes[i] = true
+	}
+	return f
+}
+
+// ParseTraceFilter parses a pc range such as "0x200-0x2FF"
+// and a comma separated list of opcode classes such as "1,2,D".
+// Empty strings do not filter anything.
+func ParseTraceFilter(pcRange string, classes string) (TraceFilter, error) {
+	f := NewTraceFilter()
+	if pcRange != "" {
+		parts := strings.SplitN(pcRange, "-", 2)
+		if len(parts) != 2 {
+			return f, fmt.Errorf("invalid pc range %q", pcRange)
+		}
+		low, err := strconv.ParseUint(parts[0], 0, 12)
+		if err != nil {
+			return f, fmt.Errorf("invalid pc range %q: %v", pcRange, err)
+		}
+		high, err := strconv.ParseUint(parts[1], 0, 12)
+		if err != nil {
+			return f, fmt.Errorf("invalid pc range %q: %v", pcRange, err)
+		}
+		f.PcLow, f.PcHigh = uint16(low), uint16(high)
+	}
+	if classes != "" {
+		f.Classes = [16]bool{}
+		for _, c := range strings.Split(classes, ",") {
+			c = strings.TrimPrefix(strings.TrimSpace(c), "0x")
+			class, err := strconv.ParseUint(c, 16, 4)
+			if err != nil {
+				return f, fmt.Errorf("invalid opcode class %q", c)
+			}
+			f.Classes[class] = true
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether a record passes the filter
+func (f TraceFilter) Match(r *TraceRecord) bool {
+	return r.Pc >= f.PcLow && r.Pc <= f.PcHigh && f.Classes[r.Opcode>>12]
+}
+
+// TraceLogger writes trace records as text or JSON lines
+type TraceLogger struct {
+	w      *bufio.Writer
+	c      io.Closer
+	json   bool
+	filter TraceFilter
+}
+
+// NewTraceLogger creates a TraceLogger writing to w in the given format
+func NewTraceLogger(w io.Writer, format string, filter TraceFilter) (*TraceLogger, error) {
+	l := &TraceLogger{w: bufio.NewWriter(w), filter: filter}
+	switch format {
+	case "text":
+	case "json":
+		l.json = true
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+	return l, nil
+}
+
+// CreateTraceLogger creates a TraceLogger writing to a file
+func CreateTraceLogger(filepath string, format string, filter TraceFilter) (*TraceLogger, error) {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return nil, err
+	}
+	l, err := NewTraceLogger(file, format, filter)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.c = file
+	return l, nil
+}
+
+type jsonTraceRecord struct {
+	Cycle    uint64      `json:"cycle"`
+	Pc       uint16      `json:"pc"`
+	Opcode   uint16      `json:"opcode"`
+	Mnemonic string      `json:"mnemonic"`
+	Regs     []RegChange `json:"regs,omitempty"`
+	I        uint16      `json:"i"`
+	Writes   []MemWrite  `json:"writes,omitempty"`
+	Events   []string    `json:"events,omitempty"`
+}
+
+// Trace writes a record when it passes the filter
+func (l *TraceLogger) Trace(r *TraceRecord) {
+	if !l.filter.Match(r) {
+		return
+	}
+	var events []string
+	for _, ev := range r.Events {
+		events = append(events, ev.String())
+	}
+	if l.json {
+		b, _ := json.Marshal(jsonTraceRecord{
+			Cycle:    r.Cycle,
+			Pc:       r.Pc,
+			Opcode:   r.Opcode,
+			Mnemonic: Disassemble(r.Opcode),
+			Regs:     r.Regs,
+			I:        r.I,
+			Writes:   r.Writes,
+			Events:   events,
+		})
+		l.w.Write(b)
+		l.w.WriteByte('\n')
+		return
+	}
+	fmt.Fprintf(l.w, "%8d %03X %04X %-18s I=%03X", r.Cycle, r.Pc, r.Opcode, Disassemble(r.Opcode), r.I)
+	for _, c := range r.Regs {
+		fmt.Fprintf(l.w, " V%X=%02X(%02X)", c.Reg, c.New, c.Old)
+	}
+	for _, w := range r.Writes {
+		fmt.Fprintf(l.w, " [%03X]=%02X(%02X)", w.Addr, w.New, w.Old)
+	}
+	for _, ev := range events {
+		fmt.Fprintf(l.w, " !%s", ev)
+	}
+	l.w.WriteByte('\n')
+}
+
+// Close flushes the trace and closes the underlying writer
+func (l *TraceLogger) Close() error {
+	err := l.w.Flush()
+	if l.c != nil {
+		if cerr := l.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type traceRecorder struct {
+	records []TraceRecord
+}
+
+func (t *traceRecorder) Trace(r *TraceRecord) {
+	t.records = append(t.records, *r)
+}
+
+func TestEmulator_StepTrace(t *testing.T) {
+	fonts := NewFonts()
+	emu := NewEmulator(fonts)
+	// LD V1, 0x7B; LD I, 0x300; LD B, V1
+	copy(emu.Memory[0x200:], []byte{0x61, 0x7B, 0xA3, 0x00, 0xF1, 0x33})
+	recorder := &traceRecorder{}
+	emu.AddTracer(recorder)
+	for i := 0; i < 3; i++ {
+		emu.Step()
+	}
+	if len(recorder.records) != 3 {
+		t.Fatalf("got: %v records,but expected: 3", len(recorder.records))
+	}
+	actual := recorder.records[0].Regs
+	if len(actual) != 1 || actual[0] != (RegChange{Reg: 1, Old: 0, New: 0x7B}) {
+		t.Errorf("got: %v,but expected: V1 0x00 -> 0x7B", actual)
+	}
+	writes := recorder.records[2].Writes
+	expected := []MemWrite{{0x300, 0, 1}, {0x301, 0, 2}, {0x302, 0, 3}}
+	if len(writes) != len(expected) {
+		t.Fatalf("got: %v,but expected: %v", writes, expected)
+	}
+	for i := range expected {
+		if writes[i] != expected[i] {
+			t.Errorf("got: %v,but expected: %v", writes[i], expected[i])
+		}
+	}
+}
+
+func TestTraceLogger_Filter(t *testing.T) {
+	filter, err := ParseTraceFilter("0x200-0x2FF", "6,A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger, _ := NewTraceLogger(&buf, "text", filter)
+	logger.Trace(&TraceRecord{Pc: 0x200, Opcode: 0x617B})
+	logger.Trace(&TraceRecord{Pc: 0x202, Opcode: 0x1202})
+	logger.Trace(&TraceRecord{Pc: 0x300, Opcode: 0xA300})
+	logger.Close()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "LD V1, 0x7B") {
+		t.Errorf("got: %q,but expected a single LD V1, 0x7B line", buf.String())
+	}
+}